@@ -0,0 +1,396 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/events"
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
+	"github.com/go-chi/chi"
+)
+
+// adminSeries describes a table that admins may edit.
+type adminSeries struct {
+	name   string // dataset name used in revisions and the audit log
+	table  string
+	fields [2]string
+	kind   string // event kind published when a day is created
+}
+
+var (
+	casesSeries   = adminSeries{name: "cases", table: "cases", fields: [2]string{"reported", "total"}, kind: events.KindCase}
+	surveysSeries = adminSeries{name: "surveys", table: "surveys", fields: [2]string{"positive", "administered"}, kind: events.KindSurvey}
+)
+
+// figureField addresses the named value within a Figures.
+func figureField(f *Figures, field string) **int {
+	switch field {
+	case "reported":
+		return &f.Reported
+	case "total":
+		return &f.Total
+	case "positive":
+		return &f.Positive
+	default:
+		return &f.Administered
+	}
+}
+
+func (s adminSeries) figures(a, b int) *Figures {
+	f := &Figures{}
+	*figureField(f, s.fields[0]) = &a
+	*figureField(f, s.fields[1]) = &b
+	return f
+}
+
+// values keys the figures of the series by field, as events store them.
+func (s adminSeries) values(f *Figures) map[string]int {
+	if f == nil {
+		return nil
+	}
+	return map[string]int{s.fields[0]: **figureField(f, s.fields[0]), s.fields[1]: **figureField(f, s.fields[1])}
+}
+
+// adminRow is the row a public day is served from: the first stored row
+// for its date.
+type adminRow struct {
+	ID     int
+	A, B   int
+	Hidden bool
+}
+
+// AdminRequest is the body of every admin write. Only the two figures of
+// the series being edited may be set.
+type AdminRequest struct {
+	Date   string `json:"date"`
+	Reason string `json:"reason"`
+	Figures
+}
+
+// AuditEntry is a row of the audit_log table.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	KeyID     int             `json:"key_id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Dataset   string          `json:"dataset"`
+	Date      string          `json:"date"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	Reason    string          `json:"reason"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type adminError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *adminError) Error() string {
+	return e.message
+}
+
+// requireAdmin only lets through requests made with an admin API key.
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := authenticate(r)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "an admin API key is required")
+			return
+		}
+		if !key.Admin {
+			writeError(w, http.StatusForbidden, "forbidden", "API key "+strconv.Itoa(key.ID)+" is not an admin key")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(withKey(r.Context(), key)))
+	})
+}
+
+func adminRoutes(r chi.Router) {
+	r.Use(requireAdmin)
+
+	r.Get("/audit", adminAuditLog)
+
+	for path, series := range map[string]adminSeries{"/cases": casesSeries, "/testing": surveysSeries} {
+		series := series
+		r.Post(path, func(w http.ResponseWriter, r *http.Request) {
+			adminWrite(w, r, series, "create")
+		})
+		r.Patch(path+"/{date}", func(w http.ResponseWriter, r *http.Request) {
+			adminWrite(w, r, series, "correct")
+		})
+		r.Post(path+"/{date}/hide", func(w http.ResponseWriter, r *http.Request) {
+			adminWrite(w, r, series, "hide")
+		})
+	}
+}
+
+// decodeAdminRequest validates the body of an admin write.
+func decodeAdminRequest(w http.ResponseWriter, r *http.Request, series adminSeries, action string) (AdminRequest, error) {
+	var req AdminRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		return req, &adminError{http.StatusBadRequest, "bad_request", "body must be a JSON object"}
+	}
+
+	if action != "create" {
+		req.Date = chi.URLParam(r, "date")
+	}
+	if !validDate(req.Date) {
+		return req, &adminError{http.StatusBadRequest, "bad_request", "date must be formatted as YYYY-MM-DD"}
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return req, &adminError{http.StatusBadRequest, "bad_request", "a reason is required"}
+	}
+
+	set := 0
+	for _, field := range []string{"reported", "total", "positive", "administered"} {
+		v := *figureField(&req.Figures, field)
+		if v == nil {
+			continue
+		}
+		if field != series.fields[0] && field != series.fields[1] {
+			return req, &adminError{http.StatusBadRequest, "bad_request", field + " is not a field of " + series.name}
+		}
+		if *v < 0 {
+			return req, &adminError{http.StatusBadRequest, "bad_request", field + " must not be negative"}
+		}
+		set++
+	}
+
+	switch {
+	case action == "create" && set != 2:
+		return req, &adminError{http.StatusBadRequest, "bad_request", series.fields[0] + " and " + series.fields[1] + " are required"}
+	case action == "correct" && set == 0:
+		return req, &adminError{http.StatusBadRequest, "bad_request", "at least one of " + series.fields[0] + " and " + series.fields[1] + " is required"}
+	case action == "hide" && set != 0:
+		return req, &adminError{http.StatusBadRequest, "bad_request", "hiding a day takes only a reason"}
+	}
+
+	return req, nil
+}
+
+// findRow returns the row date is served from, or nil if there is none,
+// and locks it. Callers hold events.LockDay, so the day cannot be added
+// while they look.
+func findRow(tx *sql.Tx, series adminSeries, date string) (*adminRow, error) {
+	id, err := events.FindDay(tx, series.table, date)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	row := adminRow{ID: id}
+	err = tx.QueryRow(`SELECT `+series.fields[0]+`, `+series.fields[1]+`, hidden
+		FROM `+series.table+` WHERE id = $1 FOR UPDATE`, id).Scan(&row.A, &row.B, &row.Hidden)
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// applyAdminWrite makes the change and its audit entry in one transaction,
+// returning the figures before and after.
+func applyAdminWrite(series adminSeries, action string, req AdminRequest, key apiKey) (before, after *Figures, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if err := events.LockDay(tx, series.table, req.Date); err != nil {
+		return nil, nil, err
+	}
+	row, err := findRow(tx, series, req.Date)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	visible := row != nil && !row.Hidden
+	if visible {
+		before = series.figures(row.A, row.B)
+	}
+
+	switch action {
+	case "create":
+		if visible {
+			return nil, nil, &adminError{http.StatusConflict, "conflict", req.Date + " already exists, correct it instead"}
+		}
+		a, b := *figureField(&req.Figures, series.fields[0]), *figureField(&req.Figures, series.fields[1])
+		after = series.figures(*a, *b)
+
+		if row != nil {
+			// bring back a hidden day with the new figures
+			_, err = tx.Exec(`UPDATE `+series.table+` SET `+series.fields[0]+` = $2, `+series.fields[1]+` = $3,
+				hidden = false, corrected_at = now(), scraped_at = now() WHERE id = $1`, row.ID, *a, *b)
+		} else {
+			// store the date the way GT writes it, like the scrapers do
+			t, _ := time.Parse("2006-01-02", req.Date)
+			_, err = tx.Exec(`INSERT INTO `+series.table+` (date, `+series.fields[0]+`, `+series.fields[1]+`, corrected_at)
+				VALUES ($1, $2, $3, now())`, t.Format(gtdate.Layouts[0]), *a, *b)
+		}
+	case "correct", "hide":
+		if !visible {
+			return nil, nil, &adminError{http.StatusNotFound, "not_found", "no " + series.name + " reported for " + req.Date}
+		}
+
+		if action == "hide" {
+			_, err = tx.Exec(`UPDATE `+series.table+` SET hidden = true, corrected_at = now(), scraped_at = now() WHERE id = $1`, row.ID)
+			break
+		}
+
+		a, b := row.A, row.B
+		if v := *figureField(&req.Figures, series.fields[0]); v != nil {
+			a = *v
+		}
+		if v := *figureField(&req.Figures, series.fields[1]); v != nil {
+			b = *v
+		}
+		after = series.figures(a, b)
+
+		_, err = tx.Exec(`UPDATE `+series.table+` SET `+series.fields[0]+` = $2, `+series.fields[1]+` = $3,
+			corrected_at = now(), scraped_at = now() WHERE id = $1`, row.ID, a, b)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	beforeJSON, _ := json.Marshal(before)
+	afterJSON, _ := json.Marshal(after)
+	_, err = tx.Exec(`INSERT INTO audit_log (key_id, actor, action, dataset, date, before, after, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		key.ID, key.Name, action, series.name, req.Date, string(beforeJSON), string(afterJSON), req.Reason)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return before, after, tx.Commit()
+}
+
+// adminWrite creates, corrects or hides a day, then refreshes the dataset
+// so that the change is served immediately, and publishes it to every
+// other instance and live subscriber.
+func adminWrite(w http.ResponseWriter, r *http.Request, series adminSeries, action string) {
+	req, err := decodeAdminRequest(w, r, series, action)
+	if err != nil {
+		e := err.(*adminError)
+		writeError(w, e.status, e.code, e.message)
+		return
+	}
+
+	key, _ := requestKey(r)
+	before, after, err := applyAdminWrite(series, action, req, key)
+	if err != nil {
+		var e *adminError
+		if errors.As(err, &e) {
+			writeError(w, e.status, e.code, e.message)
+			return
+		}
+		log.Printf("error: could not %s %s for %s: %v\n", action, series.name, req.Date, err)
+		writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+		return
+	}
+
+	log.Printf("admin: key %d (%s) %s %s %s: %s\n", key.ID, key.Name, action, series.name, req.Date, req.Reason)
+
+	if err := refreshDataset(); err != nil {
+		log.Printf("error: could not refresh dataset: %v\n", err)
+	}
+
+	if action == "create" {
+		_, err = events.Publish(ctx, db, rdb, series.kind, req.Date, after)
+	} else {
+		_, err = events.Publish(ctx, db, rdb, events.KindRevision, req.Date, events.Revision{
+			Dataset: series.name,
+			Before:  series.values(before),
+			After:   series.values(after),
+		})
+	}
+	if err != nil {
+		log.Printf("error: could not publish %s of %s for %s: %v\n", action, series.name, req.Date, err)
+	}
+
+	if action == "hide" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	d := currentDataset()
+	if series.table == "cases" {
+		if body, ok := d.v2CaseByDate[req.Date]; ok {
+			writeBody(w, body)
+			return
+		}
+	} else if body, ok := d.v2SurveyByDate[req.Date]; ok {
+		writeBody(w, body)
+		return
+	}
+	writeData(w, http.StatusOK, nil, nil)
+}
+
+// adminAuditLog serves the latest audit entries, optionally filtered by
+// dataset and date.
+func adminAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 || limit > 1000 {
+			writeError(w, http.StatusBadRequest, "bad_request", "limit must be between 1 and 1000")
+			return
+		}
+	}
+
+	dataset, date := q.Get("dataset"), q.Get("date")
+	if dataset != "" && dataset != casesSeries.name && dataset != surveysSeries.name {
+		writeError(w, http.StatusBadRequest, "bad_request", "dataset must be cases or surveys")
+		return
+	}
+	if date != "" && !validDate(date) {
+		writeError(w, http.StatusBadRequest, "bad_request", "date must be formatted as YYYY-MM-DD")
+		return
+	}
+
+	rows, err := db.Query(`SELECT id, key_id, actor, action, dataset, date, before, after, reason, created_at
+		FROM audit_log
+		WHERE ($1 = '' OR dataset = $1) AND ($2 = '' OR date = $2)
+		ORDER BY id DESC LIMIT $3`, dataset, date, limit)
+	if err != nil {
+		log.Printf("error: could not load audit log: %v\n", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+		return
+	}
+
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var e AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.KeyID, &e.Actor, &e.Action, &e.Dataset, &e.Date, &before, &after, &e.Reason, &e.CreatedAt); err != nil {
+			log.Printf("error: could not load audit log: %v\n", err)
+			writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+			return
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("error: could not load audit log: %v\n", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+		return
+	}
+
+	writeData(w, http.StatusOK, entries, ListMeta{Count: len(entries)})
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// keysChannel is published to whenever a key is created or revoked so that
+// running instances reload their key cache.
+const keysChannel = "gt.apikeys"
+
+// keyReloadInterval bounds how long a missed reload message can leave the
+// cache stale.
+const keyReloadInterval = time.Minute
+
+// keyPrefix makes keys recognisable in logs and secret scanners.
+const keyPrefix = "gtjpj_"
+
+// apiKey is an active row of the api_keys table. Only the SHA-256 of the
+// key is stored; the key itself is shown once, when it is created.
+type apiKey struct {
+	ID    int
+	Name  string
+	Rate  int  // requests per minute, 0 for the configured default
+	Admin bool // may use the /gt-jpj/admin routes
+}
+
+// activeKeys maps key hashes to keys so that requests never wait on
+// Postgres to be authenticated.
+var activeKeys atomic.Value
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func lookupKey(key string) (apiKey, bool) {
+	keys, _ := activeKeys.Load().(map[string]apiKey)
+	k, ok := keys[hashKey(key)]
+	return k, ok
+}
+
+func loadKeys() error {
+	defer observeQuery("api_keys", time.Now())
+
+	rows, err := db.Query(`SELECT id, name, key_hash, rate, admin FROM api_keys WHERE revoked_at IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	keys := make(map[string]apiKey)
+	for rows.Next() {
+		var k apiKey
+		var hash string
+		if err := rows.Scan(&k.ID, &k.Name, &hash, &k.Rate, &k.Admin); err != nil {
+			return err
+		}
+		keys[hash] = k
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	activeKeys.Store(keys)
+	return nil
+}
+
+// watchKeys reloads the key cache whenever a key changes, and periodically
+// in case a message was missed.
+func watchKeys() {
+	ticker := time.NewTicker(keyReloadInterval)
+	defer ticker.Stop()
+
+	pubsub := rdb.Subscribe(ctx, keysChannel)
+	defer pubsub.Close()
+
+	changes := pubsub.Channel()
+
+	for {
+		select {
+		case <-changes:
+		case <-ticker.C:
+		}
+
+		if err := loadKeys(); err != nil {
+			log.Printf("error: could not reload api keys: %v\n", err)
+		}
+	}
+}
+
+func generateKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return keyPrefix + hex.EncodeToString(b), nil
+}
+
+func usageKey(id int, day string) string {
+	return fmt.Sprintf("gt.usage.%d.%s", id, day)
+}
+
+// runKeyCommand implements `backend apikey ...` for managing keys.
+func runKeyCommand(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: backend apikey create -name NAME [-rate N] [-admin]")
+		fmt.Fprintln(os.Stderr, "       backend apikey list")
+		fmt.Fprintln(os.Stderr, "       backend apikey revoke ID")
+		fmt.Fprintln(os.Stderr, "       backend apikey usage [-days N] ID")
+		os.Exit(2)
+	}
+
+	if len(args) == 0 {
+		usage()
+	}
+
+	var err error
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("create", flag.ExitOnError)
+		name := fs.String("name", "", "who the key is issued to")
+		rate := fs.Int("rate", 0, "requests per minute, 0 for the configured default")
+		admin := fs.Bool("admin", false, "allow the key to correct data through the admin API")
+		fs.Parse(args[1:])
+		if *name == "" || *rate < 0 {
+			usage()
+		}
+		err = createKey(*name, *rate, *admin)
+	case "list":
+		err = listKeys()
+	case "revoke":
+		if len(args) != 2 {
+			usage()
+		}
+		id, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			usage()
+		}
+		err = revokeKey(id)
+	case "usage":
+		fs := flag.NewFlagSet("usage", flag.ExitOnError)
+		days := fs.Int("days", 7, "number of days to show")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 || *days < 1 {
+			usage()
+		}
+		id, convErr := strconv.Atoi(fs.Arg(0))
+		if convErr != nil {
+			usage()
+		}
+		err = keyUsage(id, *days)
+	default:
+		usage()
+	}
+
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+}
+
+func createKey(name string, rate int, admin bool) error {
+	key, err := generateKey()
+	if err != nil {
+		return err
+	}
+
+	var id int
+	err = db.QueryRow(`INSERT INTO api_keys (name, key_hash, rate, admin) VALUES ($1, $2, $3, $4) RETURNING id`,
+		name, hashKey(key), rate, admin).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	rdb.Publish(ctx, keysChannel, strconv.Itoa(id))
+
+	fmt.Printf("created key %d for %s\n", id, name)
+	fmt.Println(key)
+	fmt.Fprintln(os.Stderr, "the key is not stored and cannot be shown again")
+	return nil
+}
+
+func listKeys() error {
+	rows, err := db.Query(`SELECT id, name, rate, admin, created_at, revoked_at FROM api_keys ORDER BY id`)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tRATE\tADMIN\tCREATED\tREVOKED")
+	for rows.Next() {
+		var id, rate int
+		var name string
+		var admin bool
+		var created time.Time
+		var revoked sql.NullTime
+		if err := rows.Scan(&id, &name, &rate, &admin, &created, &revoked); err != nil {
+			return err
+		}
+
+		rateText := "default"
+		if rate > 0 {
+			rateText = strconv.Itoa(rate) + "/min"
+		}
+		revokedText := "-"
+		if revoked.Valid {
+			revokedText = revoked.Time.Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%t\t%s\t%s\n", id, name, rateText, admin, created.Format("2006-01-02"), revokedText)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return tw.Flush()
+}
+
+func revokeKey(id int) error {
+	res, err := db.Exec(`UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no active key with id %d", id)
+	}
+
+	rdb.Publish(ctx, keysChannel, strconv.Itoa(id))
+
+	fmt.Printf("revoked key %d\n", id)
+	return nil
+}
+
+func keyUsage(id int, days int) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATE\tREQUESTS\tLIMITED")
+
+	today := time.Now().UTC()
+	for i := days - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i).Format("2006-01-02")
+		counts, err := rdb.HGetAll(ctx, usageKey(id, day)).Result()
+		if err != nil {
+			return err
+		}
+
+		requests, limited := counts["requests"], counts["limited"]
+		if requests == "" {
+			requests = "0"
+		}
+		if limited == "" {
+			limited = "0"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", day, requests, limited)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// badgeColors are the named colors of shields.io. Any other color must be
+// given as a 3 or 6 digit hex code.
+var badgeColors = map[string]string{
+	"brightgreen": "#4c1",
+	"green":       "#97ca00",
+	"yellowgreen": "#a4a61d",
+	"yellow":      "#dfb317",
+	"orange":      "#fe7d37",
+	"red":         "#e05d44",
+	"blue":        "#007ec6",
+	"lightgrey":   "#9f9f9f",
+	"grey":        "#555",
+}
+
+var hexColor = regexp.MustCompile(`^[0-9a-fA-F]{3}([0-9a-fA-F]{3})?$`)
+
+// maxBadgeLabel bounds the label a badge URL may set.
+const maxBadgeLabel = 64
+
+// badgeMetric computes the value shown by a badge. ok is false when there
+// is no data to show yet.
+type badgeMetric struct {
+	label string
+	value func(d *dataset) (value float64, text string, ok bool)
+}
+
+var badgeMetrics = map[string]badgeMetric{
+	"reported": {"reported today", func(d *dataset) (float64, string, bool) {
+		if len(d.cases) == 0 {
+			return 0, "", false
+		}
+		n := d.cases[len(d.cases)-1].Reported
+		return float64(n), printer.Sprintf("%d", n), true
+	}},
+	"total": {"total cases", func(d *dataset) (float64, string, bool) {
+		if len(d.cases) == 0 {
+			return 0, "", false
+		}
+		n := d.cases[len(d.cases)-1].Total
+		return float64(n), printer.Sprintf("%d", n), true
+	}},
+	"average": {"7-day average", func(d *dataset) (float64, string, bool) {
+		avg, ok := d.movingAverage(len(d.cases)-1, 7)
+		return avg, fmt.Sprintf("%.1f", avg), ok
+	}},
+	"positivity": {"positivity", func(d *dataset) (float64, string, bool) {
+		if len(d.surveys) == 0 {
+			return 0, "", false
+		}
+		day := d.surveys[len(d.surveys)-1]
+		p := percentage(day.Positive, day.Administered)
+		return p, fmt.Sprintf("%.2f%%", p), true
+	}},
+}
+
+// badgeThreshold switches the badge to color once the value reaches min.
+type badgeThreshold struct {
+	min   float64
+	color string
+}
+
+func parseColor(c string) (string, bool) {
+	if named, ok := badgeColors[c]; ok {
+		return named, true
+	}
+	if hexColor.MatchString(c) {
+		return "#" + c, true
+	}
+	return "", false
+}
+
+// parseThresholds reads thresholds written as value:color pairs separated
+// by commas, e.g. 10:yellow,25:orange,50:red.
+func parseThresholds(s string) ([]badgeThreshold, error) {
+	list := []badgeThreshold{}
+	if s == "" {
+		return list, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("thresholds must be value:color pairs separated by commas")
+		}
+		min, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("threshold %q is not a number", parts[0])
+		}
+		color, ok := parseColor(parts[1])
+		if !ok {
+			return nil, fmt.Errorf("unknown color %q", parts[1])
+		}
+		list = append(list, badgeThreshold{min, color})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].min < list[j].min })
+	return list, nil
+}
+
+// textWidth approximates the width in pixels of s in 11px Verdana, which
+// is close enough to size the badge without font metrics.
+func textWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("iljtfr.,:;'!|() ", r):
+			width += 4
+		case strings.ContainsRune("mwMW%", r):
+			width += 11
+		case r >= 'A' && r <= 'Z':
+			width += 8
+		default:
+			width += 7
+		}
+	}
+	return width
+}
+
+// renderBadge draws a flat shields-style badge.
+func renderBadge(label, value, color string) string {
+	lw, vw := textWidth(label)+10, textWidth(value)+10
+	w := lw + vw
+	label, value = html.EscapeString(label), html.EscapeString(value)
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<title>%[4]s: %[5]s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[7]d" y="14">%[4]s</text>`+
+		`<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[5]s</text><text x="%[8]d" y="14">%[5]s</text>`+
+		`</g></svg>`,
+		w, lw, vw, label, value, color, lw/2, lw+vw/2)
+}
+
+// getBadge serves /gt-jpj/badge/{metric}.svg. The label, the base color
+// and color thresholds can be set with the label, color and thresholds
+// query parameters.
+func getBadge(w http.ResponseWriter, r *http.Request) {
+	metric, ok := badgeMetrics[chi.URLParam(r, "metric")]
+	if !ok {
+		names := make([]string, 0, len(badgeMetrics))
+		for name := range badgeMetrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeError(w, http.StatusNotFound, "not_found", "metric must be one of "+strings.Join(names, ", "))
+		return
+	}
+
+	q := r.URL.Query()
+
+	label := metric.label
+	if l, ok := q["label"]; ok {
+		label = l[0]
+	}
+	if len(label) > maxBadgeLabel {
+		writeError(w, http.StatusBadRequest, "bad_request", "label must be at most "+strconv.Itoa(maxBadgeLabel)+" bytes")
+		return
+	}
+
+	color := badgeColors["brightgreen"]
+	if c := q.Get("color"); c != "" {
+		if color, ok = parseColor(c); !ok {
+			writeError(w, http.StatusBadRequest, "bad_request", "unknown color "+strconv.Quote(c))
+			return
+		}
+	}
+
+	thresholds, err := parseThresholds(q.Get("thresholds"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	value, text, ok := metric.value(requestDataset(r))
+	if ok {
+		for _, t := range thresholds {
+			if value >= t.min {
+				color = t.color
+			}
+		}
+	} else {
+		text, color = "no data", badgeColors["lightgrey"]
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+	w.Write([]byte(renderBadge(label, text, color)))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// cacheControl lets clients and proxies reuse a dataset response for a
+// minute before revalidating it with the ETag.
+const cacheControl = "public, max-age=60, must-revalidate"
+
+type datasetKey struct{}
+
+// requestDataset returns the dataset a request was validated against by
+// cacheDataset, so the body always matches the ETag that was sent.
+func requestDataset(r *http.Request) *dataset {
+	if d, ok := r.Context().Value(datasetKey{}).(*dataset); ok {
+		return d
+	}
+	return currentDataset()
+}
+
+// cacheDataset sets validators for responses derived from the dataset and
+// answers conditional requests with 304 Not Modified. The ETag is weak as
+// the same data is served identity, gzip or brotli encoded, which differ
+// in bytes.
+func cacheDataset(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := currentDataset()
+		etag := `"` + d.version + `"`
+
+		h := w.Header()
+		h.Set("ETag", "W/"+etag)
+		h.Set("Vary", "Accept-Encoding")
+		h.Set("Cache-Control", cacheControl)
+		if !d.lastModified.IsZero() {
+			h.Set("Last-Modified", d.lastModified.UTC().Format(http.TimeFormat))
+		}
+
+		if notModified(r, etag, d.lastModified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), datasetKey{}, d)))
+	})
+}
+
+// notModified evaluates If-None-Match with the weak comparison, etag being
+// the opaque tag without W/, falling back to If-Modified-Since only when
+// the former is absent (RFC 7232 section 6).
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+
+	return false
+}
+
+func encoderBrotli(w io.Writer, level int) io.Writer {
+	return brotli.NewWriterLevel(w, level)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2020, 9, 21, 12, 0, 30, 500, time.UTC)
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{"no validators", "GET", nil, false},
+		{"strong match", "GET", map[string]string{"If-None-Match": `"v1"`}, true},
+		{"weak match", "GET", map[string]string{"If-None-Match": `W/"v1"`}, true},
+		{"match in list", "GET", map[string]string{"If-None-Match": `"v0", W/"v1"`}, true},
+		{"wildcard", "GET", map[string]string{"If-None-Match": "*"}, true},
+		{"no match", "GET", map[string]string{"If-None-Match": `"v0"`}, false},
+		{"unquoted", "GET", map[string]string{"If-None-Match": "v1"}, false},
+		{"head", "HEAD", map[string]string{"If-None-Match": `"v1"`}, true},
+		{"post", "POST", map[string]string{"If-None-Match": `"v1"`}, false},
+		{"modified since", "GET", map[string]string{"If-Modified-Since": "Mon, 21 Sep 2020 12:00:00 GMT"}, false},
+		{"not modified since", "GET", map[string]string{"If-Modified-Since": "Mon, 21 Sep 2020 12:00:30 GMT"}, true},
+		{"bad date", "GET", map[string]string{"If-Modified-Since": "yesterday"}, false},
+		{"etag takes precedence", "GET", map[string]string{
+			"If-None-Match":     `"v0"`,
+			"If-Modified-Since": "Mon, 21 Sep 2020 13:00:00 GMT",
+		}, false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/gt-jpj/v2/cases", nil)
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+		if got := notModified(r, `"v1"`, modified); got != tt.want {
+			t.Errorf("%s: notModified = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCacheDataset(t *testing.T) {
+	current.Store(&dataset{version: "v1", lastModified: time.Date(2020, 9, 21, 12, 0, 0, 0, time.UTC)})
+
+	handler := cacheDataset(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+
+	tests := []struct {
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"", http.StatusOK},
+		{`W/"v1"`, http.StatusNotModified},
+		{`"v1"`, http.StatusNotModified},
+		{`W/"v0"`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/gt-jpj/v2/cases", nil)
+		if tt.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("If-None-Match %q: status %d, want %d", tt.ifNoneMatch, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("ETag"); got != `W/"v1"` {
+			t.Errorf("If-None-Match %q: ETag %q, want W/\"v1\"", tt.ifNoneMatch, got)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("If-None-Match %q: Vary %q, want Accept-Encoding", tt.ifNoneMatch, got)
+		}
+		if got := w.Header().Get("Last-Modified"); got != "Mon, 21 Sep 2020 12:00:00 GMT" {
+			t.Errorf("If-None-Match %q: Last-Modified %q", tt.ifNoneMatch, got)
+		}
+		if got := w.Header().Get("Cache-Control"); got != cacheControl {
+			t.Errorf("If-None-Match %q: Cache-Control %q, want %s", tt.ifNoneMatch, got, cacheControl)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package client is a typed Go client for the GT JPJ Tracking API. It mirrors
+// the document served at /gt-jpj/openapi.json and talks to the /v2 routes.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the public deployment of the API.
+const DefaultBaseURL = "https://api.aditya.diwakar.io/gt-jpj"
+
+// DefaultTimeout bounds a whole request, including reading the body.
+const DefaultTimeout = 10 * time.Second
+
+// Corrected marks days entered or corrected by hand by the API's admins
+// rather than taken from GT as published.
+type CaseDay struct {
+	ID        int    `json:"id"`
+	Date      string `json:"date"`
+	Reported  int    `json:"reported"`
+	Total     int    `json:"total"`
+	Corrected bool   `json:"corrected"`
+}
+
+type SurveyDay struct {
+	ID           int    `json:"id"`
+	Date         string `json:"date"`
+	Positive     int    `json:"positive"`
+	Administered int    `json:"administered"`
+	Corrected    bool   `json:"corrected"`
+}
+
+// Error is returned for any response carrying a /v2 error envelope.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gt-jpj: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+type envelope struct {
+	Data  json.RawMessage `json:"data"`
+	Meta  json.RawMessage `json:"meta"`
+	Error *Error          `json:"error"`
+}
+
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+type Option func(*Client)
+
+// WithBaseURL points the client at another deployment, for example
+// http://localhost:3000/gt-jpj during development.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithTimeout replaces DefaultTimeout. A zero timeout disables it.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithAPIKey sends key with every request, for the higher rate limit that
+// comes with it.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithHTTPClient uses hc for every request, e.g. to share a transport.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+func New(opts ...Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Client) get(ctx context.Context, path string, data interface{}) error {
+	req, err := http.NewRequest("GET", c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	var env envelope
+	if err := json.NewDecoder(res.Body).Decode(&env); err != nil {
+		return fmt.Errorf("gt-jpj: decoding %s: %v", path, err)
+	}
+
+	if env.Error != nil {
+		env.Error.StatusCode = res.StatusCode
+		return env.Error
+	}
+	if res.StatusCode != http.StatusOK {
+		return &Error{StatusCode: res.StatusCode, Code: "unexpected_status", Message: res.Status}
+	}
+
+	return json.Unmarshal(env.Data, data)
+}
+
+// Cases returns every reported day, oldest first.
+func (c *Client) Cases(ctx context.Context) ([]CaseDay, error) {
+	var days []CaseDay
+	err := c.get(ctx, "/v2/cases", &days)
+	return days, err
+}
+
+// Case returns the day reported on date, formatted as YYYY-MM-DD.
+func (c *Client) Case(ctx context.Context, date string) (CaseDay, error) {
+	var day CaseDay
+	err := c.get(ctx, "/v2/cases/"+url.PathEscape(date), &day)
+	return day, err
+}
+
+// Surveys returns every surveillance testing result, oldest first.
+func (c *Client) Surveys(ctx context.Context) ([]SurveyDay, error) {
+	var days []SurveyDay
+	err := c.get(ctx, "/v2/testing", &days)
+	return days, err
+}
+
+// Survey returns the surveillance testing result for date, formatted as
+// YYYY-MM-DD.
+func (c *Client) Survey(ctx context.Context, date string) (SurveyDay, error) {
+	var day SurveyDay
+	err := c.get(ctx, "/v2/testing/"+url.PathEscape(date), &day)
+	return day, err
+}
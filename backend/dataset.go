@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/events"
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
+	"github.com/lib/pq"
+)
+
+// defaultRefreshInterval is used when Dataset.RefreshInterval is unset.
+const defaultRefreshInterval = 15 * time.Minute
+
+type datasetConfig struct {
+	RefreshInterval int // seconds between reloads when no update is published
+}
+
+// dataset is an immutable, precomputed view of both series. Handlers only
+// read from it; refreshDataset swaps in a new one when the data changes.
+type dataset struct {
+	loadedAt time.Time
+
+	// version is a hash of the data, identical across instances serving
+	// the same rows. It is used as the ETag of every dataset response.
+	version string
+
+	// latest scraped_at of each series, and the later of the two
+	casesScrapedAt   time.Time
+	surveysScrapedAt time.Time
+	lastModified     time.Time
+
+	cases   []CaseDay
+	surveys []SurveyDay
+
+	// caseIndex maps the ID of each day to its index in cases, and
+	// averages holds the moving average ending on each day per window
+	caseIndex map[int]int
+	averages  map[int][]float64
+
+	// storedDates maps each ISO date to the dates stored for it, as GT
+	// published them, so that events can be looked up by date in SQL
+	storedDates map[string][]string
+
+	// response bodies, encoded once per refresh
+	legacyCases    []byte
+	legacySurveys  []byte
+	v2Cases        []byte
+	v2Surveys      []byte
+	v2CaseByDate   map[string][]byte
+	v2SurveyByDate map[string][]byte
+}
+
+var current atomic.Value
+
+func currentDataset() *dataset {
+	return current.Load().(*dataset)
+}
+
+func encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+var errBadRange = errors.New("from and to must be formatted as YYYY-MM-DD")
+
+// checkRange validates optional from and to bounds.
+func checkRange(from, to string) error {
+	if (from != "" && !validDate(from)) || (to != "" && !validDate(to)) {
+		return errBadRange
+	}
+	return nil
+}
+
+// inRange reports whether date lies within the inclusive bounds. Dates are
+// YYYY-MM-DD, so they compare correctly as strings.
+func inRange(date, from, to string) bool {
+	return (from == "" || date >= from) && (to == "" || date <= to)
+}
+
+// datesBetween returns every date stored within the inclusive bounds,
+// either of which may be empty, in ISO 8601 and as GT published it.
+func (d *dataset) datesBetween(from, to string) []string {
+	dates := make([]string, 0)
+	for iso, stored := range d.storedDates {
+		if inRange(iso, from, to) {
+			dates = append(dates, iso)
+			dates = append(dates, stored...)
+		}
+	}
+	return dates
+}
+
+// casesBetween returns the reported days within the inclusive bounds,
+// either of which may be empty.
+func (d *dataset) casesBetween(from, to string) []CaseDay {
+	days := make([]CaseDay, 0)
+	for _, day := range d.cases {
+		if inRange(day.Date, from, to) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// surveysBetween is casesBetween for the surveillance results.
+func (d *dataset) surveysBetween(from, to string) []SurveyDay {
+	days := make([]SurveyDay, 0)
+	for _, day := range d.surveys {
+		if inRange(day.Date, from, to) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// averageWindows are the moving averages precomputed for every day.
+var averageWindows = []int{7, 30}
+
+// movingAverages averages the cases reported over the window days ending
+// with each day. Days with fewer days before them than that are left 0.
+func movingAverages(cases []CaseDay, window int) []float64 {
+	averages := make([]float64, len(cases))
+
+	sum := 0
+	for i, d := range cases {
+		sum += d.Reported
+		if i >= window {
+			sum -= cases[i-window].Reported
+		}
+		if i+1 >= window {
+			averages[i] = float64(sum) / float64(window)
+		}
+	}
+	return averages
+}
+
+// movingAverage is the average over the window days ending with cases[i].
+// It reports false when there are fewer days than that.
+func (d *dataset) movingAverage(i, window int) (float64, bool) {
+	averages, ok := d.averages[window]
+	if !ok || i < 0 || i+1 < window {
+		return 0, false
+	}
+	return averages[i], true
+}
+
+// lastScraped returns the latest scraped_at in table, or the zero time if
+// the table is empty.
+func lastScraped(table string) (time.Time, error) {
+	defer observeQuery("last_scraped_"+table, time.Now())
+
+	var t pq.NullTime
+	err := db.QueryRow(`SELECT MAX(scraped_at) FROM ` + table).Scan(&t)
+	return t.Time, err
+}
+
+// datedCases deduplicates rows for /v2, keeping the lowest ID of each day
+// so that IDs are stable, and orders the days by date, since days added
+// through the admin API may be older than the last one scraped.
+func datedCases(rows []CasesRow) []CasesRow {
+	rows = append([]CasesRow(nil), rows...)
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	rows = uniqueCases(rows)
+	sort.SliceStable(rows, func(i, j int) bool { return gtdate.ISO(rows[i].Date) < gtdate.ISO(rows[j].Date) })
+	return rows
+}
+
+// datedSurveys deduplicates and orders rows in the same way as datedCases.
+func datedSurveys(rows []SurveysRow) []SurveysRow {
+	rows = append([]SurveysRow(nil), rows...)
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	rows = uniqueSurveys(rows)
+	sort.SliceStable(rows, func(i, j int) bool { return gtdate.ISO(rows[i].Date) < gtdate.ISO(rows[j].Date) })
+	return rows
+}
+
+func loadDataset() (*dataset, error) {
+	storedCases, err := queryCases()
+	if err != nil {
+		return nil, err
+	}
+
+	storedSurveys, err := querySurveys()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	stored := make([]string, 0, len(storedCases)+len(storedSurveys))
+	for _, row := range storedCases {
+		stored = append(stored, row.Date)
+	}
+	for _, row := range storedSurveys {
+		stored = append(stored, row.Date)
+	}
+
+	// the legacy endpoints keep the order they have always been served in
+	legacyCases, legacySurveys := uniqueCases(storedCases), uniqueSurveys(storedSurveys)
+	caseRows, surveyRows := datedCases(storedCases), datedSurveys(storedSurveys)
+
+	d := &dataset{
+		loadedAt:       time.Now(),
+		cases:          make([]CaseDay, len(caseRows)),
+		surveys:        make([]SurveyDay, len(surveyRows)),
+		caseIndex:      make(map[int]int, len(caseRows)),
+		averages:       make(map[int][]float64, len(averageWindows)),
+		storedDates:    make(map[string][]string),
+		v2CaseByDate:   make(map[string][]byte, len(caseRows)),
+		v2SurveyByDate: make(map[string][]byte, len(surveyRows)),
+	}
+
+	for i, row := range caseRows {
+		d.cases[i] = CaseDay{
+			ID:        row.ID,
+			Date:      gtdate.ISO(row.Date),
+			Reported:  row.Reported,
+			Total:     row.Total,
+			Corrected: row.Corrected,
+		}
+		d.caseIndex[row.ID] = i
+		if d.v2CaseByDate[d.cases[i].Date], err = encode(Envelope{Data: d.cases[i]}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, date := range stored {
+		date = strings.TrimSpace(date)
+		if iso := gtdate.ISO(date); date != iso && !seen[date] {
+			seen[date] = true
+			d.storedDates[iso] = append(d.storedDates[iso], date)
+		}
+	}
+
+	for _, window := range averageWindows {
+		d.averages[window] = movingAverages(d.cases, window)
+	}
+
+	for i, row := range surveyRows {
+		d.surveys[i] = SurveyDay{
+			ID:           row.ID,
+			Date:         gtdate.ISO(row.Date),
+			Positive:     row.Positive,
+			Administered: row.Administered,
+			Corrected:    row.Corrected,
+		}
+		if d.v2SurveyByDate[d.surveys[i].Date], err = encode(Envelope{Data: d.surveys[i]}); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.v2Cases, err = encode(Envelope{Data: d.cases, Meta: ListMeta{Count: len(d.cases)}}); err != nil {
+		return nil, err
+	}
+	if d.v2Surveys, err = encode(Envelope{Data: d.surveys, Meta: ListMeta{Count: len(d.surveys)}}); err != nil {
+		return nil, err
+	}
+
+	// legacy clients expect IDs to be renumbered after deduplication
+	for i := range legacyCases {
+		legacyCases[i].ID = i + 1
+	}
+	for i := range legacySurveys {
+		legacySurveys[i].ID = i + 1
+	}
+
+	if d.legacyCases, err = encode(CaseResponse{Payload: legacyCases, Code: 200}); err != nil {
+		return nil, err
+	}
+	if d.legacySurveys, err = encode(SurveyResponse{Payload: legacySurveys, Code: 200}); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.New()
+	sum.Write(d.v2Cases)
+	sum.Write(d.v2Surveys)
+	d.version = hex.EncodeToString(sum.Sum(nil)[:16])
+
+	if d.casesScrapedAt, err = lastScraped("cases"); err != nil {
+		return nil, err
+	}
+	if d.surveysScrapedAt, err = lastScraped("surveys"); err != nil {
+		return nil, err
+	}
+
+	d.lastModified = d.casesScrapedAt
+	if d.surveysScrapedAt.After(d.lastModified) {
+		d.lastModified = d.surveysScrapedAt
+	}
+
+	return d, nil
+}
+
+func refreshInterval() time.Duration {
+	if conf.Dataset.RefreshInterval > 0 {
+		return time.Duration(conf.Dataset.RefreshInterval) * time.Second
+	}
+	return defaultRefreshInterval
+}
+
+// refreshDataset reloads both series from Postgres. On error the previous
+// dataset keeps being served.
+func refreshDataset() error {
+	d, err := loadDataset()
+	if err != nil {
+		return err
+	}
+
+	current.Store(d)
+	return nil
+}
+
+// watchDataset refreshes the dataset whenever a scraper publishes to
+// events.Channel, and on a timer in case a notification is missed. Published
+// events are passed on to the live feeds once the dataset reflects them.
+func watchDataset() {
+	ticker := time.NewTicker(refreshInterval())
+	defer ticker.Stop()
+
+	pubsub := rdb.Subscribe(ctx, events.Channel)
+	defer pubsub.Close()
+
+	updates := pubsub.Channel()
+
+	for {
+		var event *events.Event
+
+		select {
+		case msg := <-updates:
+			event = &events.Event{}
+			if err := json.Unmarshal([]byte(msg.Payload), event); err != nil {
+				log.Printf("error: could not decode update %q: %v\n", msg.Payload, err)
+				event = nil
+			}
+		case <-ticker.C:
+		}
+
+		if err := refreshDataset(); err != nil {
+			log.Printf("error: could not refresh dataset: %v\n", err)
+		}
+
+		if event != nil {
+			event.Date = gtdate.ISO(event.Date)
+			hub.publish(*event)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
+)
+
+func TestDatedCases(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []CasesRow
+		want string // ID:date of each day served
+	}{
+		{
+			"scraped in order",
+			[]CasesRow{{ID: 1, Date: "September 1, 2020"}, {ID: 2, Date: "September 2, 2020"}},
+			"[1:2020-09-01 2:2020-09-02]",
+		},
+		{
+			"back-dated by an admin",
+			[]CasesRow{{ID: 1, Date: "September 1, 2020"}, {ID: 2, Date: "September 3, 2020"}, {ID: 3, Date: "September 2, 2020"}},
+			"[1:2020-09-01 3:2020-09-02 2:2020-09-03]",
+		},
+		{
+			"lowest ID of a day",
+			[]CasesRow{{ID: 4, Date: "9/2/2020"}, {ID: 2, Date: "September 2, 2020"}, {ID: 1, Date: "September 1, 2020"}},
+			"[1:2020-09-01 2:2020-09-02]",
+		},
+		{
+			"hidden day",
+			[]CasesRow{{ID: 1, Date: "September 2, 2020", Hidden: true}, {ID: 2, Date: "September 2, 2020"}, {ID: 3, Date: "September 1, 2020"}},
+			"[3:2020-09-01]",
+		},
+		{
+			"across years",
+			[]CasesRow{{ID: 1, Date: "January 4, 2021"}, {ID: 2, Date: "December 30, 2020"}},
+			"[2:2020-12-30 1:2021-01-04]",
+		},
+	}
+
+	for _, tt := range tests {
+		days := []string{}
+		for _, row := range datedCases(tt.rows) {
+			days = append(days, fmt.Sprintf("%d:%s", row.ID, gtdate.ISO(row.Date)))
+		}
+		if got := fmt.Sprint(days); got != tt.want {
+			t.Errorf("%s: datedCases = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDatedSurveys(t *testing.T) {
+	rows := []SurveysRow{{ID: 1, Date: "September 3, 2020"}, {ID: 2, Date: "September 1, 2020"}, {ID: 3, Date: "Sep 3, 2020"}}
+	got := datedSurveys(rows)
+	if len(got) != 2 || got[0].ID != 2 || got[1].ID != 1 {
+		t.Errorf("datedSurveys = %+v, want rows 2 and 1", got)
+	}
+	if rows[0].ID != 1 || rows[1].ID != 2 {
+		t.Errorf("datedSurveys reordered its argument: %+v", rows)
+	}
+}
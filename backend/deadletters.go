@@ -0,0 +1,197 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/notify"
+)
+
+func runDeadLetterCommand(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: backend deadletter list [-all]")
+		fmt.Fprintln(os.Stderr, "       backend deadletter show ID")
+		fmt.Fprintln(os.Stderr, "       backend deadletter redeliver ID... | -all")
+		fmt.Fprintln(os.Stderr, "       backend deadletter discard ID")
+		fmt.Fprintln(os.Stderr, "       backend deadletter log [-n N] SUBSCRIPTION")
+		os.Exit(2)
+	}
+
+	if len(args) == 0 {
+		usage()
+	}
+
+	var err error
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("list", flag.ExitOnError)
+		all := fs.Bool("all", false, "include redelivered messages")
+		fs.Parse(args[1:])
+		err = listDeadLetters(*all)
+	case "show":
+		if len(args) != 2 {
+			usage()
+		}
+		id, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			usage()
+		}
+		err = showDeadLetter(id)
+	case "redeliver":
+		fs := flag.NewFlagSet("redeliver", flag.ExitOnError)
+		all := fs.Bool("all", false, "redeliver every pending dead letter")
+		fs.Parse(args[1:])
+		if *all == (fs.NArg() > 0) {
+			usage()
+		}
+		ids := []int{}
+		for _, arg := range fs.Args() {
+			id, convErr := strconv.Atoi(arg)
+			if convErr != nil {
+				usage()
+			}
+			ids = append(ids, id)
+		}
+		err = redeliver(ids, *all)
+	case "discard":
+		if len(args) != 2 {
+			usage()
+		}
+		id, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			usage()
+		}
+		if err = notify.DiscardDeadLetter(db, id); err == nil {
+			fmt.Printf("discarded dead letter %d\n", id)
+		}
+	case "log":
+		fs := flag.NewFlagSet("log", flag.ExitOnError)
+		n := fs.Int("n", 20, "number of deliveries to show")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 || *n < 1 {
+			usage()
+		}
+		id, convErr := strconv.Atoi(fs.Arg(0))
+		if convErr != nil {
+			usage()
+		}
+		err = deliveryLog(id, *n)
+	default:
+		usage()
+	}
+
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+}
+
+func listDeadLetters(all bool) error {
+	list, err := notify.DeadLetters(db, all)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSUBSCRIPTION\tTYPE\tTEMPLATE\tATTEMPTS\tCREATED\tREDELIVERED\tERROR")
+	for _, l := range list {
+		subscription := "deleted"
+		if l.Subscription.Valid {
+			subscription = strconv.FormatInt(l.Subscription.Int64, 10)
+		}
+		redelivered := "-"
+		if l.RedeliveredAt.Valid {
+			redelivered = l.RedeliveredAt.Time.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", l.ID, subscription, l.Destination.Type,
+			l.Template, l.Attempts, l.CreatedAt.Format("2006-01-02 15:04"), redelivered, l.Error)
+	}
+
+	return tw.Flush()
+}
+
+func showDeadLetter(id int) error {
+	l, err := notify.GetDeadLetter(db, id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("template: %s\ntype: %s\nerror: %s (%d attempts)\n", l.Template, l.Destination.Type, l.Error, l.Attempts)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l.Message)
+}
+
+func redeliver(ids []int, all bool) error {
+	if all {
+		list, err := notify.DeadLetters(db, false)
+		if err != nil {
+			return err
+		}
+		for _, l := range list {
+			ids = append(ids, l.ID)
+		}
+	}
+
+	failed := 0
+	for _, id := range ids {
+		if err := notify.Redeliver(ctx, db, id); err != nil {
+			log.Printf("error: could not redeliver dead letter %d: %v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Printf("redelivered dead letter %d\n", id)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d dead letters could not be redelivered", failed, len(ids))
+	}
+	return nil
+}
+
+func deliveryLog(subscription int, n int) error {
+	rows, err := db.Query(`SELECT template, delivered, attempts, status_code, error, created_at FROM deliveries
+		WHERE subscription_id = $1
+		ORDER BY id DESC
+		LIMIT $2`, subscription, n)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SENT\tTEMPLATE\tDELIVERED\tATTEMPTS\tSTATUS\tERROR")
+	for rows.Next() {
+		var template string
+		var delivered bool
+		var attempts int
+		var statusCode sql.NullInt64
+		var deliveryErr sql.NullString
+		var created time.Time
+		if err := rows.Scan(&template, &delivered, &attempts, &statusCode, &deliveryErr, &created); err != nil {
+			return err
+		}
+
+		status, message := "-", "-"
+		if statusCode.Valid {
+			status = strconv.FormatInt(statusCode.Int64, 10)
+		}
+		if deliveryErr.Valid {
+			message = deliveryErr.String
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%d\t%s\t%s\n", created.Format("2006-01-02 15:04"), template,
+			delivered, attempts, status, message)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return tw.Flush()
+}
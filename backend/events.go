@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/events"
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
+)
+
+// sseHeartbeat keeps idle connections from being closed by proxies.
+const sseHeartbeat = 30 * time.Second
+
+// broker fans published events out to every live subscriber.
+type broker struct {
+	mu          sync.Mutex
+	subscribers map[chan events.Event]struct{}
+}
+
+var hub = &broker{subscribers: make(map[chan events.Event]struct{})}
+
+func (b *broker) subscribe() chan events.Event {
+	ch := make(chan events.Event, 16)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch
+}
+
+func (b *broker) unsubscribe(ch chan events.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish never blocks. A subscriber whose buffer is full is dropped and its
+// channel closed; it is expected to reconnect and resume from the last event
+// it saw.
+func (b *broker) publish(e events.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// eventsSince returns every event stored after id, oldest first.
+func eventsSince(id int64) ([]events.Event, error) {
+	defer observeQuery("events_since", time.Now())
+
+	rows, err := db.Query(`SELECT id, kind, date, payload, created_at FROM events WHERE id > $1 ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := make([]events.Event, 0)
+	for rows.Next() {
+		e := events.Event{}
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Date, &e.Data, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Date = gtdate.ISO(e.Date)
+		list = append(list, e)
+	}
+
+	return list, rows.Err()
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Kind, data)
+	return err
+}
+
+// streamEvents serves /gt-jpj/events as Server-Sent Events. Clients that
+// reconnect with Last-Event-ID first receive everything they missed.
+func streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "internal", "streaming is not supported")
+		return
+	}
+
+	var lastID int64
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		var err error
+		if lastID, err = strconv.ParseInt(id, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", "Last-Event-ID must be an event id")
+			return
+		}
+	}
+
+	// subscribe before backfilling so nothing published in between is lost
+	live := hub.subscribe()
+	defer hub.unsubscribe(live)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if lastID > 0 {
+		missed, err := eventsSince(lastID)
+		if err != nil {
+			log.Printf("error: could not load events since %d: %v\n", lastID, err)
+			return
+		}
+		for _, e := range missed {
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+			lastID = e.ID
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-shuttingDown:
+			// clients reconnect with Last-Event-ID to another instance
+			return
+		case e, ok := <-live:
+			if !ok {
+				return
+			}
+			if e.ID <= lastID {
+				continue
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+			lastID = e.ID
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,189 @@
+// Package events stores events in the events table and publishes them to
+// the backend, which refreshes its dataset and forwards them to live
+// subscribers. The scrapers and the admin API both publish through it.
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+)
+
+// Channel is the Redis channel events are published to. Each message is an
+// Event encoded as JSON.
+const Channel = "gt.updates"
+
+// Event kinds.
+const (
+	KindCase     = "case"
+	KindSurvey   = "survey"
+	KindRevision = "revision"
+	KindAlert    = "alert"
+)
+
+// Event is a row of the events table. Data holds the stored figures; for a
+// revision it holds the dataset name and the values before and after.
+type Event struct {
+	ID        int64           `json:"id"`
+	Kind      string          `json:"kind"`
+	Date      string          `json:"date"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Revision is the data of a revision event.
+type Revision struct {
+	Dataset string         `json:"dataset"`
+	Before  map[string]int `json:"before"`
+	After   map[string]int `json:"after"`
+}
+
+// Alert is the data of an alert event: a fired alert rule along with the
+// figures of the day.
+type Alert struct {
+	Dataset     string
+	Rule        string
+	Severity    string
+	Description string
+	Values      map[string]int
+}
+
+// MarshalJSON writes the figures next to the rule, as they have always
+// been stored.
+func (a Alert) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"dataset":     a.Dataset,
+		"rule":        a.Rule,
+		"severity":    a.Severity,
+		"description": a.Description,
+	}
+	for field, v := range a.Values {
+		m[field] = v
+	}
+	return json.Marshal(m)
+}
+
+// fields are the figures stored for each dataset, which is also the name
+// of its table.
+var fields = map[string][2]string{
+	"cases":   {"reported", "total"},
+	"surveys": {"positive", "administered"},
+}
+
+// Publish stores an event and publishes it on Channel.
+func Publish(ctx context.Context, db *sql.DB, rdb *redis.Client, kind, date string, data interface{}) (Event, error) {
+	e := Event{Kind: kind, Date: strings.TrimSpace(date)}
+
+	var err error
+	if e.Data, err = json.Marshal(data); err != nil {
+		return e, err
+	}
+
+	err = db.QueryRow(`INSERT INTO events (kind, date, payload) VALUES ($1, $2, $3) RETURNING id, created_at`,
+		e.Kind, e.Date, string(e.Data)).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return e, err
+	}
+
+	msg, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+
+	return e, rdb.Publish(ctx, Channel, msg).Err()
+}
+
+// LockDay takes a lock on date in dataset that is held until tx ends. The
+// admin API and the scrapers take it before looking for a day and adding
+// it, so that they never both add the same day.
+func LockDay(tx *sql.Tx, dataset, date string) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, dataset+"/"+gtdate.ISO(date))
+	return err
+}
+
+// FindDay returns the ID of the first row stored for date in dataset,
+// in any of the forms GT has written it, or sql.ErrNoRows.
+func FindDay(tx *sql.Tx, dataset, date string) (int, error) {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM `+dataset+` WHERE TRIM(date) = ANY($1) ORDER BY id LIMIT 1`,
+		pq.Array(gtdate.Forms(date))).Scan(&id)
+	return id, err
+}
+
+// AddDay stores the figures GT published for date in dataset and returns
+// the ID of the row. If the day is already stored, by the admin API for
+// instance, it returns that row instead, since the first row of a day is
+// the one served.
+func AddDay(db *sql.DB, dataset, date string, values map[string]int) (int, error) {
+	f := fields[dataset]
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := LockDay(tx, dataset, date); err != nil {
+		return 0, err
+	}
+	id, err := FindDay(tx, dataset, date)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`INSERT INTO `+dataset+` (date, `+f[0]+`, `+f[1]+`) VALUES ($1, $2, $3) RETURNING id`,
+			date, values[f[0]], values[f[1]]).Scan(&id)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// Revise compares the figures stored for date in dataset with after, what
+// GT shows now. If GT has corrected them, it updates the stored rows,
+// publishes a revision and returns the figures before. Days corrected or
+// hidden by an admin take precedence over GT and are left alone, so before
+// is nil if nothing was revised.
+func Revise(ctx context.Context, db *sql.DB, rdb *redis.Client, dataset, date string, after map[string]int) (map[string]int, error) {
+	date = strings.TrimSpace(date)
+	f := fields[dataset]
+
+	var a, b int
+	var manual bool
+	err := db.QueryRow(`SELECT `+f[0]+`, `+f[1]+`, hidden OR corrected_at IS NOT NULL
+		FROM `+dataset+` WHERE TRIM(date) = $1 ORDER BY id LIMIT 1`, date).Scan(&a, &b, &manual)
+	if err == sql.ErrNoRows || manual {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	before := map[string]int{f[0]: a, f[1]: b}
+	if before[f[0]] == after[f[0]] && before[f[1]] == after[f[1]] {
+		return nil, nil
+	}
+
+	_, err = db.Exec(`UPDATE `+dataset+` SET `+f[0]+` = $2, `+f[1]+` = $3, scraped_at = now()
+		WHERE TRIM(date) = $1`, date, after[f[0]], after[f[1]])
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = Publish(ctx, db, rdb, KindRevision, date, Revision{Dataset: dataset, Before: before, After: after})
+	return before, err
+}
+
+// Alerted reports whether rule has already fired for date in dataset.
+// The scrapers name their rules independently, so the same name may be
+// used for either dataset.
+func Alerted(db *sql.DB, dataset, rule, date string) (bool, error) {
+	var seen bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM events
+		WHERE kind = $1 AND date = $2 AND payload->>'dataset' = $3 AND payload->>'rule' = $4)`,
+		KindAlert, strings.TrimSpace(date), dataset, rule).Scan(&seen)
+	return seen, err
+}
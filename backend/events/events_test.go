@@ -0,0 +1,114 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
+	"github.com/adityaxdiwakar/gt-cases/backend/testenv"
+	"github.com/lib/pq"
+)
+
+func TestAddDay(t *testing.T) {
+	db := testenv.Database(t)
+	_, err := db.Exec(`CREATE TABLE cases (
+		id       SERIAL PRIMARY KEY,
+		date     TEXT NOT NULL,
+		reported INTEGER NOT NULL,
+		total    INTEGER NOT NULL
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored int
+	if err := db.QueryRow(`INSERT INTO cases (date, reported, total) VALUES ('Sep 1, 2020 ', 1, 2) RETURNING id`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dates   []string // added at once
+		wantNew bool
+	}{
+		{"stored in another form", []string{"September 1, 2020"}, false},
+		{"stored as ISO", []string{"2020-09-01"}, false},
+		{"new day", []string{"September 2, 2020"}, true},
+		{"racing", []string{"September 3, 2020", "September 3, 2020", "9/3/2020", "Sep. 3, 2020", "2020-09-03", "Thursday, September 3, 2020"}, true},
+	}
+
+	for _, tt := range tests {
+		ids := make([]int, len(tt.dates))
+		errs := make([]error, len(tt.dates))
+		var wg sync.WaitGroup
+		for i, date := range tt.dates {
+			wg.Add(1)
+			go func(i int, date string) {
+				defer wg.Done()
+				ids[i], errs[i] = AddDay(db, "cases", date, map[string]int{"reported": 3, "total": 5})
+			}(i, date)
+		}
+		wg.Wait()
+
+		for i := range ids {
+			if errs[i] != nil {
+				t.Fatalf("%s: %v", tt.name, errs[i])
+			}
+			if ids[i] != ids[0] {
+				t.Errorf("%s: AddDay returned rows %v, want one", tt.name, ids)
+				break
+			}
+		}
+		if isNew := ids[0] != stored; isNew != tt.wantNew {
+			t.Errorf("%s: added a row %v, want %v", tt.name, isNew, tt.wantNew)
+		}
+
+		var rows int
+		if err := db.QueryRow(`SELECT count(*) FROM cases WHERE TRIM(date) = ANY($1)`, pq.Array(gtdate.Forms(tt.dates[0]))).Scan(&rows); err != nil {
+			t.Fatal(err)
+		}
+		if rows != 1 {
+			t.Errorf("%s: %d rows stored for the day, want 1", tt.name, rows)
+		}
+	}
+}
+
+func TestAlerted(t *testing.T) {
+	db := testenv.Database(t)
+	_, err := db.Exec(`CREATE TABLE events (
+		id      BIGSERIAL PRIMARY KEY,
+		kind    TEXT NOT NULL,
+		date    TEXT NOT NULL,
+		payload JSONB NOT NULL
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, _ := json.Marshal(Alert{Dataset: "cases", Rule: "spike", Values: map[string]int{"reported": 10}})
+	if _, err := db.Exec(`INSERT INTO events (kind, date, payload) VALUES ($1, $2, $3)`, KindAlert, "September 1, 2020", string(payload)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dataset, rule, date string
+		want                bool
+	}{
+		{"cases", "spike", "September 1, 2020", true},
+		{"cases", "spike", " September 1, 2020 ", true},
+		{"surveys", "spike", "September 1, 2020", false},
+		{"cases", "high", "September 1, 2020", false},
+		{"cases", "spike", "September 2, 2020", false},
+	}
+
+	for _, tt := range tests {
+		got, err := Alerted(db, tt.dataset, tt.rule, tt.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Alerted(%s, %s, %q) = %v, want %v", tt.dataset, tt.rule, tt.date, got, tt.want)
+		}
+	}
+}
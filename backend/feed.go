@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/events"
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
+	"github.com/gorilla/feeds"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// feedEntries bounds the number of entries in each feed, newest first.
+const feedEntries = 100
+
+const (
+	feedLink           = "https://api.aditya.diwakar.io/gt-jpj"
+	casesSource        = "https://health.gatech.edu/coronavirus/health-alerts"
+	surveillanceSource = "https://health.gatech.edu/surveillance-testing-program-results"
+)
+
+var printer = message.NewPrinter(language.English)
+
+// feedTimes are the times an entry was first published and last revised,
+// taken from the events table.
+type feedTimes struct {
+	published time.Time
+	revised   time.Time
+}
+
+// feedCache holds the feeds rendered for one dataset version, so that the
+// events table is only read once per refresh.
+var feedCache struct {
+	sync.Mutex
+	version string
+	atom    string
+	rss     string
+}
+
+// loadFeedTimes returns the first publication and latest revision of every
+// day, keyed by dataset and ISO date.
+func loadFeedTimes() (map[string]*feedTimes, error) {
+	defer observeQuery("feed_times", time.Now())
+
+	rows, err := db.Query(`SELECT kind, COALESCE(payload->>'dataset', ''), date, MIN(created_at), MAX(created_at)
+		FROM events WHERE kind IN ('case', 'survey', 'revision')
+		GROUP BY 1, 2, 3`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	times := make(map[string]*feedTimes)
+	for rows.Next() {
+		var kind, dataset, date string
+		var first, last time.Time
+		if err := rows.Scan(&kind, &dataset, &date, &first, &last); err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case events.KindCase:
+			dataset = "cases"
+		case events.KindSurvey:
+			dataset = "surveys"
+		}
+
+		key := dataset + "/" + gtdate.ISO(strings.TrimSpace(date))
+		t, ok := times[key]
+		if !ok {
+			t = &feedTimes{}
+			times[key] = t
+		}
+		if kind == events.KindRevision {
+			t.revised = last
+		} else {
+			t.published = first
+		}
+	}
+
+	return times, rows.Err()
+}
+
+func signed(n int) string {
+	if n > 0 {
+		return printer.Sprintf("+%d", n)
+	}
+	return printer.Sprintf("%d", n)
+}
+
+// newFeedItem fills in the times of an entry. Days scraped before events
+// were recorded are dated at midnight UTC.
+func newFeedItem(dataset, date string, times map[string]*feedTimes) *feeds.Item {
+	item := &feeds.Item{Id: "tag:api.aditya.diwakar.io,2020:gt-jpj/" + dataset + "/" + date}
+
+	item.Created, _ = time.Parse("2006-01-02", date)
+	item.Updated = item.Created
+	if t, ok := times[dataset+"/"+date]; ok {
+		if !t.published.IsZero() {
+			item.Created, item.Updated = t.published, t.published
+		}
+		if t.revised.After(item.Updated) {
+			item.Updated = t.revised
+		}
+	}
+	return item
+}
+
+// buildFeed renders one entry per case day and surveillance result with
+// the figures of the Discord alerts. A revised day keeps its ID and gets a
+// new updated time.
+func buildFeed(d *dataset) (*feeds.Feed, error) {
+	times, err := loadFeedTimes()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*feeds.Item, 0, len(d.cases)+len(d.surveys))
+
+	for i, day := range d.cases {
+		item := newFeedItem("cases", day.Date, times)
+		item.Title = fmt.Sprintf("[%s] GT COVID-19 Update", day.Date)
+		item.Link = &feeds.Link{Href: casesSource}
+
+		lines := []string{
+			printer.Sprintf("Reported Today: %d", day.Reported),
+			printer.Sprintf("Total: %d", day.Total),
+		}
+		seven, ok7 := d.movingAverage(i, 7)
+		thirty, ok30 := d.movingAverage(i, 30)
+		if ok7 && ok30 {
+			lines = append(lines, fmt.Sprintf("7/30 Day MA: %.1f/%.1f", seven, thirty))
+		}
+		item.Description = strings.Join(lines, "<br>")
+		items = append(items, item)
+	}
+
+	for i, day := range d.surveys {
+		item := newFeedItem("surveys", day.Date, times)
+		item.Title = fmt.Sprintf("[%s] Surveillance Testing Program Results", day.Date)
+		item.Link = &feeds.Link{Href: surveillanceSource}
+
+		positive := printer.Sprintf("Tested Positive (All Time): %d", day.Positive)
+		administered := printer.Sprintf("Tests Administered: %d", day.Administered)
+		if i > 0 {
+			prev := d.surveys[i-1]
+			positive += " (" + signed(day.Positive-prev.Positive) + ")"
+			administered += " (" + signed(day.Administered-prev.Administered) + ")"
+		}
+		item.Description = positive + "<br>" + administered
+		items = append(items, item)
+	}
+
+	for _, item := range items {
+		if item.Updated.After(item.Created) {
+			item.Title += " (Revised)"
+		}
+	}
+
+	// newest first, cases before surveys on the same day
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Created.After(items[j].Created)
+	})
+	if len(items) > feedEntries {
+		items = items[:feedEntries]
+	}
+
+	feed := &feeds.Feed{
+		Title:       "GT JPJ Tracking",
+		Link:        &feeds.Link{Href: feedLink},
+		Description: "Daily COVID-19 cases and surveillance testing results reported by Georgia Tech - Not affiliated with Georgia Tech",
+		Id:          "tag:api.aditya.diwakar.io,2020:gt-jpj",
+		Items:       items,
+	}
+	for _, item := range items {
+		if item.Updated.After(feed.Updated) {
+			feed.Updated = item.Updated
+		}
+	}
+
+	return feed, nil
+}
+
+// renderedFeeds returns the Atom and RSS documents for d, rendering them
+// only when the dataset has changed.
+func renderedFeeds(d *dataset) (atom, rss string, err error) {
+	feedCache.Lock()
+	defer feedCache.Unlock()
+
+	if feedCache.version == d.version {
+		return feedCache.atom, feedCache.rss, nil
+	}
+
+	feed, err := buildFeed(d)
+	if err != nil {
+		return "", "", err
+	}
+	if atom, err = feed.ToAtom(); err != nil {
+		return "", "", err
+	}
+	if rss, err = feed.ToRss(); err != nil {
+		return "", "", err
+	}
+
+	feedCache.version, feedCache.atom, feedCache.rss = d.version, atom, rss
+	return atom, rss, nil
+}
+
+func serveFeed(w http.ResponseWriter, r *http.Request, format string) {
+	atom, rss, err := renderedFeeds(requestDataset(r))
+	if err != nil {
+		log.Printf("error: could not render feeds: %v\n", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+		return
+	}
+
+	if format == "atom" {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		w.Write([]byte(atom))
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(rss))
+}
+
+func getAtomFeed(w http.ResponseWriter, r *http.Request) {
+	serveFeed(w, r, "atom")
+}
+
+func getRSSFeed(w http.ResponseWriter, r *http.Request) {
+	serveFeed(w, r, "rss")
+}
@@ -3,8 +3,19 @@ module github.com/adityaxdiwakar/gt-cases/backend
 go 1.14
 
 require (
-	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/BurntSushi/toml v0.3.1
+	github.com/andybalholm/brotli v1.0.0
+	github.com/bwmarrin/discordgo v0.22.0
 	github.com/go-chi/chi v4.1.2+incompatible
-	github.com/lib/pq v1.8.0 // indirect
+	github.com/go-redis/redis/v8 v8.0.0-beta.7
+	github.com/golang/protobuf v1.4.2
+	github.com/gorilla/feeds v1.1.1
+	github.com/gorilla/websocket v1.4.2
+	github.com/graphql-go/graphql v0.7.9
+	github.com/lib/pq v1.8.0
+	github.com/prometheus/client_golang v1.7.1
 	golang.org/x/net v0.0.0-20200813134508-3edf25e44fcc // indirect
+	golang.org/x/text v0.3.3
+	google.golang.org/grpc v1.32.0
+	google.golang.org/protobuf v1.25.0
 )
@@ -0,0 +1,643 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/events"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// Limits applied to every GraphQL query before it is executed, unless
+// overridden by the GraphQL section of config.toml. A list counts as many
+// items as it can return, so the default complexity allows every field of
+// a few hundred days.
+const (
+	defaultMaxDepth      = 6
+	defaultMaxComplexity = 10000
+
+	// maxListItems is the most items any list field returns. Larger last
+	// and limit arguments are capped to it.
+	maxListItems = 1000
+
+	// defaultRevisionsLimit is the limit of revisions when none is given.
+	defaultRevisionsLimit = 50
+)
+
+type graphQLConfig struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// Figures holds whichever values a revision changed. Case revisions set
+// Reported and Total, survey revisions set Positive and Administered.
+type Figures struct {
+	Reported     *int `json:"reported,omitempty"`
+	Total        *int `json:"total,omitempty"`
+	Positive     *int `json:"positive,omitempty"`
+	Administered *int `json:"administered,omitempty"`
+}
+
+type Revision struct {
+	ID        int64
+	Date      string
+	Dataset   string
+	Before    Figures
+	After     Figures
+	CreatedAt string
+}
+
+type Stats struct {
+	Days              int
+	TotalCases        int
+	PeakDay           interface{} // CaseDay, or nil without any cases
+	AverageReported   float64
+	SevenDayAverage   float64
+	TestsAdministered int
+	PositiveTests     int
+	SurveillanceDays  int
+	Positivity        float64
+}
+
+var dateRangeArgs = graphql.FieldConfigArgument{
+	"from": &graphql.ArgumentConfig{Type: graphql.String, Description: "First date to include, YYYY-MM-DD"},
+	"to":   &graphql.ArgumentConfig{Type: graphql.String, Description: "Last date to include, YYYY-MM-DD"},
+}
+
+var caseDayType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CaseDay",
+	Fields: graphql.Fields{
+		"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"date":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"reported": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"total":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"corrected": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Description: "Whether the day was entered or corrected by hand",
+		},
+		"sevenDayAverage": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Average reported over the seven days ending on this day",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return caseAverage(p.Source.(CaseDay), 7), nil
+			},
+		},
+		"thirtyDayAverage": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Average reported over the thirty days ending on this day",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return caseAverage(p.Source.(CaseDay), 30), nil
+			},
+		},
+	},
+})
+
+var surveyDayType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "SurveyDay",
+	Fields: graphql.Fields{
+		"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"date":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"positive":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"administered": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"corrected": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Description: "Whether the result was entered or corrected by hand",
+		},
+		"positivity": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Percentage of administered tests that were positive",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				day := p.Source.(SurveyDay)
+				return percentage(day.Positive, day.Administered), nil
+			},
+		},
+	},
+})
+
+var figuresType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Figures",
+	Fields: graphql.Fields{
+		"reported":     &graphql.Field{Type: graphql.Int},
+		"total":        &graphql.Field{Type: graphql.Int},
+		"positive":     &graphql.Field{Type: graphql.Int},
+		"administered": &graphql.Field{Type: graphql.Int},
+	},
+})
+
+var revisionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Revision",
+	Fields: graphql.Fields{
+		"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"date":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"dataset":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"before":    &graphql.Field{Type: graphql.NewNonNull(figuresType)},
+		"after":     &graphql.Field{Type: graphql.NewNonNull(figuresType)},
+		"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var statsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Stats",
+	Fields: graphql.Fields{
+		"days":              &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"totalCases":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"peakDay":           &graphql.Field{Type: caseDayType},
+		"averageReported":   &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"sevenDayAverage":   &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"testsAdministered": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"positiveTests":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"surveillanceDays":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"positivity":        &graphql.Field{Type: graphql.NewNonNull(graphql.Float), Description: "Percentage of administered tests that were positive"},
+	},
+})
+
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"cases": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(caseDayType))),
+			Args: withLast(dateRangeArgs),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				days, err := casesInRange(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				last, err := lastArgument(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				if last < len(days) {
+					days = days[len(days)-last:]
+				}
+				return days, nil
+			},
+		},
+		"case": &graphql.Field{
+			Type: caseDayType,
+			Args: graphql.FieldConfigArgument{
+				"date": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				for _, day := range currentDataset().cases {
+					if day.Date == p.Args["date"].(string) {
+						return day, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+		"surveys": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(surveyDayType))),
+			Args: withLast(dateRangeArgs),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				days, err := surveysInRange(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				last, err := lastArgument(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				if last < len(days) {
+					days = days[len(days)-last:]
+				}
+				return days, nil
+			},
+		},
+		"survey": &graphql.Field{
+			Type: surveyDayType,
+			Args: graphql.FieldConfigArgument{
+				"date": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				for _, day := range currentDataset().surveys {
+					if day.Date == p.Args["date"].(string) {
+						return day, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+		"stats": &graphql.Field{
+			Type: graphql.NewNonNull(statsType),
+			Args: dateRangeArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				cases, err := casesInRange(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				surveys, err := surveysInRange(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				return computeStats(cases, surveys), nil
+			},
+		},
+		"revisions": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(revisionType))),
+			Args: graphql.FieldConfigArgument{
+				"from":  dateRangeArgs["from"],
+				"to":    dateRangeArgs["to"],
+				"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultRevisionsLimit, Description: "Only return the latest revisions, at most 1000"},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return revisionsInRange(p.Args)
+			},
+		},
+	},
+})
+
+var schema graphql.Schema
+
+func init() {
+	var err error
+	schema, err = graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+	if err != nil {
+		log.Fatalf("error: invalid graphql schema: %v\n", err)
+	}
+}
+
+func withLast(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	withLast := graphql.FieldConfigArgument{
+		"last": &graphql.ArgumentConfig{Type: graphql.Int, Description: "Only return the latest days, at most 1000"},
+	}
+	for name, arg := range args {
+		withLast[name] = arg
+	}
+	return withLast
+}
+
+// lastArgument reads the last argument, capped to maxListItems, which is
+// also how many days are returned without it.
+func lastArgument(args map[string]interface{}) (int, error) {
+	last, ok := args["last"].(int)
+	if !ok || last > maxListItems {
+		return maxListItems, nil
+	}
+	if last < 0 {
+		return 0, errors.New("last must not be negative")
+	}
+	return last, nil
+}
+
+// dateRange reads the from and to arguments. Missing bounds are empty.
+func dateRange(args map[string]interface{}) (string, string, error) {
+	from, _ := args["from"].(string)
+	to, _ := args["to"].(string)
+	return from, to, checkRange(from, to)
+}
+
+func casesInRange(args map[string]interface{}) ([]CaseDay, error) {
+	from, to, err := dateRange(args)
+	if err != nil {
+		return nil, err
+	}
+	return currentDataset().casesBetween(from, to), nil
+}
+
+func surveysInRange(args map[string]interface{}) ([]SurveyDay, error) {
+	from, to, err := dateRange(args)
+	if err != nil {
+		return nil, err
+	}
+	return currentDataset().surveysBetween(from, to), nil
+}
+
+func revisionsInRange(args map[string]interface{}) ([]Revision, error) {
+	from, to, err := dateRange(args)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, ok := args["limit"].(int)
+	if !ok {
+		limit = defaultRevisionsLimit
+	}
+	if limit < 1 {
+		return nil, errors.New("limit must be at least 1")
+	}
+	if limit > maxListItems {
+		limit = maxListItems
+	}
+
+	var list []events.Event
+	if from == "" && to == "" {
+		list, err = recentEvents(events.KindRevision, limit)
+	} else {
+		list, err = recentEventsOn(events.KindRevision, currentDataset().datesBetween(from, to), limit)
+	}
+	if err != nil {
+		log.Printf("error: could not load revisions: %v\n", err)
+		return nil, errors.New("Internal Server Error")
+	}
+
+	revisions := make([]Revision, 0, len(list))
+	for _, e := range list {
+		var data struct {
+			Dataset string  `json:"dataset"`
+			Before  Figures `json:"before"`
+			After   Figures `json:"after"`
+		}
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, Revision{
+			ID:        e.ID,
+			Date:      e.Date,
+			Dataset:   data.Dataset,
+			Before:    data.Before,
+			After:     data.After,
+			CreatedAt: e.CreatedAt.UTC().Format("2006-01-02T15:04:05Z07:00"),
+		})
+	}
+	return revisions, nil
+}
+
+// caseAverage is the mean reported over the window days ending on day, or
+// nil if fewer days than that have been reported.
+func caseAverage(day CaseDay, window int) interface{} {
+	d := currentDataset()
+	i, ok := d.caseIndex[day.ID]
+	if !ok {
+		return nil
+	}
+	if avg, ok := d.movingAverage(i, window); ok {
+		return avg
+	}
+	return nil
+}
+
+func percentage(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(whole)
+}
+
+func computeStats(cases []CaseDay, surveys []SurveyDay) Stats {
+	stats := Stats{Days: len(cases), SurveillanceDays: len(surveys)}
+
+	peak := -1
+	for i := range cases {
+		stats.TotalCases += cases[i].Reported
+		if peak < 0 || cases[i].Reported > cases[peak].Reported {
+			peak = i
+		}
+	}
+	if peak >= 0 {
+		stats.PeakDay = cases[peak]
+		stats.AverageReported = float64(stats.TotalCases) / float64(len(cases))
+	}
+
+	week := cases
+	if len(week) > 7 {
+		week = week[len(week)-7:]
+	}
+	if len(week) > 0 {
+		sum := 0
+		for _, d := range week {
+			sum += d.Reported
+		}
+		stats.SevenDayAverage = float64(sum) / float64(len(week))
+	}
+
+	// survey figures are running totals, so the latest result covers them all
+	if len(surveys) > 0 {
+		latest := surveys[len(surveys)-1]
+		stats.TestsAdministered = latest.Administered
+		stats.PositiveTests = latest.Positive
+		stats.Positivity = percentage(latest.Positive, latest.Administered)
+	}
+
+	return stats
+}
+
+// introspectionLists are the list fields of the introspection types. Each
+// counts as many items as the schema has types, the most any of them
+// returns.
+var introspectionLists = map[string]bool{
+	"types": true, "fields": true, "args": true, "inputFields": true,
+	"interfaces": true, "possibleTypes": true, "enumValues": true, "directives": true,
+}
+
+// checkLimits rejects queries that nest deeper than maxDepth or whose
+// estimated cost exceeds maxComplexity, reading arguments passed as
+// variables from variables. Introspection fields count like any other.
+func checkLimits(doc *ast.Document, variables map[string]interface{}, maxDepth, maxComplexity int) error {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[f.Name.Value] = f
+		}
+	}
+
+	var vars map[string]interface{}
+	var walk func(set *ast.SelectionSet, depth int, introspecting bool, seen map[string]bool) (int, int, error)
+	walk = func(set *ast.SelectionSet, depth int, introspecting bool, seen map[string]bool) (int, int, error) {
+		if set == nil {
+			return depth, 0, nil
+		}
+		if depth > maxDepth {
+			return depth, 0, fmt.Errorf("query is nested deeper than %d levels", maxDepth)
+		}
+
+		deepest, cost := depth, 0
+		for _, sel := range set.Selections {
+			var d, c int
+			var err error
+
+			switch sel := sel.(type) {
+			case *ast.Field:
+				inside := introspecting || strings.HasPrefix(sel.Name.Value, "__")
+				d, c, err = walk(sel.SelectionSet, depth+1, inside, seen)
+				if inside {
+					c = (1 + c) * introspectionMultiplier(sel)
+				} else {
+					c = (1 + c) * fieldMultiplier(sel, vars)
+				}
+			case *ast.InlineFragment:
+				d, c, err = walk(sel.SelectionSet, depth, introspecting, seen)
+			case *ast.FragmentSpread:
+				name := sel.Name.Value
+				if seen[name] || fragments[name] == nil {
+					continue
+				}
+				seen[name] = true
+				d, c, err = walk(fragments[name].SelectionSet, depth, introspecting, seen)
+				delete(seen, name)
+			}
+			if err != nil {
+				return 0, 0, err
+			}
+
+			if d > deepest {
+				deepest = d
+			}
+			cost += c
+			if cost > maxComplexity {
+				return 0, 0, fmt.Errorf("query complexity exceeds %d", maxComplexity)
+			}
+		}
+		return deepest, cost, nil
+	}
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		// variables the request leaves out take their default
+		vars = make(map[string]interface{}, len(variables))
+		for name, v := range variables {
+			vars[name] = v
+		}
+		for _, v := range op.VariableDefinitions {
+			if _, ok := vars[v.Variable.Name.Value]; !ok && v.DefaultValue != nil {
+				vars[v.Variable.Name.Value] = v.DefaultValue.GetValue()
+			}
+		}
+
+		if _, _, err := walk(op.SelectionSet, 0, false, make(map[string]bool)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldMultiplier estimates how many items a field returns: as many as
+// its last or limit argument asks for, up to what the list can hold.
+func fieldMultiplier(field *ast.Field, vars map[string]interface{}) int {
+	var arg string
+	var n int
+	switch field.Name.Value {
+	case "cases":
+		arg, n = "last", len(currentDataset().cases)
+	case "surveys":
+		arg, n = "last", len(currentDataset().surveys)
+	case "revisions":
+		arg, n = "limit", defaultRevisionsLimit
+	default:
+		return 1
+	}
+	if n > maxListItems {
+		n = maxListItems
+	}
+
+	if v, ok := intArgument(field, arg, vars); ok && v >= 0 && (v < n || arg == "limit") {
+		n = v
+	}
+	if n > maxListItems {
+		n = maxListItems
+	}
+	return n
+}
+
+// introspectionMultiplier estimates how many items an introspection field
+// returns.
+func introspectionMultiplier(field *ast.Field) int {
+	if introspectionLists[field.Name.Value] {
+		return len(schema.TypeMap())
+	}
+	return 1
+}
+
+// intArgument reads the integer argument name of field, given literally or
+// as a variable.
+func intArgument(field *ast.Field, name string, vars map[string]interface{}) (int, bool) {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != name {
+			continue
+		}
+
+		value := arg.Value.GetValue()
+		if v, ok := arg.Value.(*ast.Variable); ok {
+			value = vars[v.Name.Value]
+		}
+		switch v := value.(type) {
+		case string: // a literal
+			n, err := strconv.Atoi(v)
+			return n, err == nil
+		case float64: // a variable decoded from JSON
+			return int(v), true
+		case int:
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+func writeGraphQLError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(graphql.Result{
+		Errors: []gqlerrors.FormattedError{{Message: message}},
+	})
+}
+
+// serveGraphQL serves /gt-jpj/graphql. Queries are accepted as a JSON POST
+// body or in the query string of a GET.
+func serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+
+	if r.Method == "POST" {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			writeGraphQLError(w, http.StatusBadRequest, "body must be a JSON GraphQL request")
+			return
+		}
+	} else {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	maxDepth, maxComplexity := defaultMaxDepth, defaultMaxComplexity
+	if conf.GraphQL.MaxDepth > 0 {
+		maxDepth = conf.GraphQL.MaxDepth
+	}
+	if conf.GraphQL.MaxComplexity > 0 {
+		maxComplexity = conf.GraphQL.MaxComplexity
+	}
+
+	if err := checkLimits(doc, req.Variables, maxDepth, maxComplexity); err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        r.Context(),
+	})
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+func TestCheckLimits(t *testing.T) {
+	current.Store(&dataset{cases: make([]CaseDay, 2000), surveys: make([]SurveyDay, 10)})
+	types := len(schema.TypeMap())
+
+	tests := []struct {
+		name          string
+		query         string
+		variables     map[string]interface{}
+		maxDepth      int
+		maxComplexity int
+		wantErr       bool
+	}{
+		{"literal last", `{ cases(last: 5) { date reported } }`, nil, 6, 15, false},
+		{"literal last over", `{ cases(last: 5) { date reported } }`, nil, 6, 14, true},
+		{"variable last", `query($n: Int) { cases(last: $n) { date reported } }`, map[string]interface{}{"n": 5.0}, 6, 15, false},
+		{"variable last over", `query($n: Int) { cases(last: $n) { date reported } }`, map[string]interface{}{"n": 5.0}, 6, 14, true},
+		{"variable default", `query($n: Int = 5) { cases(last: $n) { date reported } }`, nil, 6, 15, false},
+		{"variable default over", `query($n: Int = 5) { cases(last: $n) { date reported } }`, nil, 6, 14, true},
+		{"variable overrides default", `query($n: Int = 1) { cases(last: $n) { date } }`, map[string]interface{}{"n": 5.0}, 6, 9, true},
+		{"unbounded list is capped", `{ cases { date } }`, nil, 6, 2000, false},
+		{"unbounded list is capped over", `{ cases { date } }`, nil, 6, 1999, true},
+		{"last beyond the cap", `{ cases(last: 5000) { date } }`, nil, 6, 1999, true},
+		{"short list", `{ surveys { date } }`, nil, 6, 20, false},
+		{"short list over", `{ surveys { date } }`, nil, 6, 19, true},
+		{"revisions default", `{ revisions { id } }`, nil, 6, 100, false},
+		{"revisions default over", `{ revisions { id } }`, nil, 6, 99, true},
+		{"revisions variable", `query($l: Int) { revisions(limit: $l) { id } }`, map[string]interface{}{"l": 5000.0}, 6, 1999, true},
+		{"fragment", `query { ...f } fragment f on Query { cases(last: 5) { date } }`, nil, 6, 10, false},
+		{"fragment over", `query { ...f } fragment f on Query { cases(last: 5) { date } }`, nil, 6, 9, true},
+		{"depth", `{ cases(last: 1) { date } }`, nil, 1, 100, false},
+		{"depth over", `{ cases(last: 1) { date } }`, nil, 0, 100, true},
+		{"introspection", `{ __schema { types { name } } }`, nil, 6, 1 + 2*types, false},
+		{"introspection over", `{ __schema { types { name } } }`, nil, 6, 2 * types, true},
+		{"introspection nested", `{ __schema { types { fields { type { fields { name } } } } } }`, nil, 5, 100000000, false},
+		{"introspection nested over", `{ __schema { types { fields { type { fields { name } } } } } }`, nil, 4, 100000000, true},
+		{"introspection typename", `{ __typename }`, nil, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		doc, err := parser.Parse(parser.ParseParams{Source: tt.query})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		err = checkLimits(doc, tt.variables, tt.maxDepth, tt.maxComplexity)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkLimits = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/events"
+	"github.com/adityaxdiwakar/gt-cases/backend/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultGRPCAddress is used when GRPC.Address is unset.
+const defaultGRPCAddress = ":3001"
+
+type grpcConfig struct {
+	Address  string
+	Disabled bool
+}
+
+// trackingServer implements pb.TrackingServer on top of the same dataset and
+// event broker as the HTTP handlers.
+type trackingServer struct {
+	pb.UnimplementedTrackingServer
+}
+
+func caseDayPB(day CaseDay) *pb.CaseDay {
+	return &pb.CaseDay{
+		Id:        int64(day.ID),
+		Date:      day.Date,
+		Reported:  int32(day.Reported),
+		Total:     int32(day.Total),
+		Corrected: day.Corrected,
+	}
+}
+
+func surveyDayPB(day SurveyDay) *pb.SurveyDay {
+	return &pb.SurveyDay{
+		Id:           int64(day.ID),
+		Date:         day.Date,
+		Positive:     int32(day.Positive),
+		Administered: int32(day.Administered),
+		Corrected:    day.Corrected,
+	}
+}
+
+func int32PB(v *int) *int32 {
+	if v == nil {
+		return nil
+	}
+	n := int32(*v)
+	return &n
+}
+
+func figuresPB(f Figures) *pb.Figures {
+	return &pb.Figures{
+		Reported:     int32PB(f.Reported),
+		Total:        int32PB(f.Total),
+		Positive:     int32PB(f.Positive),
+		Administered: int32PB(f.Administered),
+	}
+}
+
+func eventPB(e events.Event) (*pb.Event, error) {
+	msg := &pb.Event{
+		Id:        e.ID,
+		Kind:      e.Kind,
+		Date:      e.Date,
+		CreatedAt: timestamppb.New(e.CreatedAt),
+	}
+
+	if e.Kind == events.KindRevision {
+		var data struct {
+			Dataset string  `json:"dataset"`
+			Before  Figures `json:"before"`
+			After   Figures `json:"after"`
+		}
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return nil, err
+		}
+		msg.Data = &pb.Event_Revision{Revision: &pb.Revision{
+			Dataset: data.Dataset,
+			Before:  figuresPB(data.Before),
+			After:   figuresPB(data.After),
+		}}
+		return msg, nil
+	}
+
+	var figures Figures
+	if err := json.Unmarshal(e.Data, &figures); err != nil {
+		return nil, err
+	}
+	msg.Data = &pb.Event_Figures{Figures: figuresPB(figures)}
+	return msg, nil
+}
+
+func (trackingServer) ListCases(ctx context.Context, req *pb.ListCasesRequest) (*pb.ListCasesResponse, error) {
+	if err := checkRange(req.From, req.To); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	res := &pb.ListCasesResponse{}
+	for _, day := range currentDataset().casesBetween(req.From, req.To) {
+		res.Cases = append(res.Cases, caseDayPB(day))
+	}
+	return res, nil
+}
+
+func (trackingServer) GetCase(ctx context.Context, req *pb.GetCaseRequest) (*pb.CaseDay, error) {
+	if !validDate(req.Date) {
+		return nil, status.Error(codes.InvalidArgument, "date must be formatted as YYYY-MM-DD")
+	}
+
+	for _, day := range currentDataset().cases {
+		if day.Date == req.Date {
+			return caseDayPB(day), nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "no cases reported for "+req.Date)
+}
+
+func (trackingServer) ListSurveys(ctx context.Context, req *pb.ListSurveysRequest) (*pb.ListSurveysResponse, error) {
+	if err := checkRange(req.From, req.To); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	res := &pb.ListSurveysResponse{}
+	for _, day := range currentDataset().surveysBetween(req.From, req.To) {
+		res.Surveys = append(res.Surveys, surveyDayPB(day))
+	}
+	return res, nil
+}
+
+func (trackingServer) GetSurvey(ctx context.Context, req *pb.GetSurveyRequest) (*pb.SurveyDay, error) {
+	if !validDate(req.Date) {
+		return nil, status.Error(codes.InvalidArgument, "date must be formatted as YYYY-MM-DD")
+	}
+
+	for _, day := range currentDataset().surveys {
+		if day.Date == req.Date {
+			return surveyDayPB(day), nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "no surveillance results for "+req.Date)
+}
+
+func (trackingServer) GetStats(ctx context.Context, req *pb.GetStatsRequest) (*pb.Stats, error) {
+	if err := checkRange(req.From, req.To); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	d := currentDataset()
+	stats := computeStats(d.casesBetween(req.From, req.To), d.surveysBetween(req.From, req.To))
+
+	res := &pb.Stats{
+		Days:              int32(stats.Days),
+		TotalCases:        int32(stats.TotalCases),
+		AverageReported:   stats.AverageReported,
+		SevenDayAverage:   stats.SevenDayAverage,
+		TestsAdministered: int32(stats.TestsAdministered),
+		PositiveTests:     int32(stats.PositiveTests),
+		SurveillanceDays:  int32(stats.SurveillanceDays),
+		Positivity:        stats.Positivity,
+	}
+	if peak, ok := stats.PeakDay.(CaseDay); ok {
+		res.PeakDay = caseDayPB(peak)
+	}
+	return res, nil
+}
+
+func (trackingServer) Watch(req *pb.WatchRequest, stream pb.Tracking_WatchServer) error {
+	kinds := make(map[string]bool)
+	for _, kind := range req.Kinds {
+		kinds[kind] = true
+	}
+
+	send := func(e events.Event) error {
+		if len(kinds) > 0 && !kinds[e.Kind] {
+			return nil
+		}
+		msg, err := eventPB(e)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return stream.Send(msg)
+	}
+
+	// subscribe before backfilling so nothing published in between is lost
+	live := hub.subscribe()
+	defer hub.unsubscribe(live)
+
+	lastID := req.AfterId
+	if lastID > 0 {
+		missed, err := eventsSince(lastID)
+		if err != nil {
+			log.Printf("error: could not load events since %d: %v\n", lastID, err)
+			return status.Error(codes.Internal, "could not load missed events")
+		}
+		for _, e := range missed {
+			if err := send(e); err != nil {
+				return err
+			}
+			lastID = e.ID
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-shuttingDown:
+			return status.Errorf(codes.Unavailable, "server is shutting down, resume with after_id %d", lastID)
+		case e, ok := <-live:
+			if !ok {
+				return status.Errorf(codes.ResourceExhausted, "client fell behind, resume with after_id %d", lastID)
+			}
+			if e.ID <= lastID {
+				continue
+			}
+			if err := send(e); err != nil {
+				return err
+			}
+			lastID = e.ID
+		}
+	}
+}
+
+// newGRPCServer registers the service and opens its listener on its own
+// port.
+func newGRPCServer() (*grpc.Server, net.Listener, error) {
+	address := defaultGRPCAddress
+	if conf.GRPC.Address != "" {
+		address = conf.GRPC.Address
+	}
+
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not listen for grpc on %s: %v", address, err)
+	}
+
+	server := grpc.NewServer()
+	pb.RegisterTrackingServer(server, trackingServer{})
+	return server, lis, nil
+}
+
+// stopGRPC lets in-flight calls finish, cutting them off when ctx expires.
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		server.Stop()
+	}
+}
@@ -0,0 +1,69 @@
+// Package gtdate reads dates as GT has published them on its health pages.
+package gtdate
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ISOLayout is the layout of dates in the API, YYYY-MM-DD.
+const ISOLayout = "2006-01-02"
+
+// Layouts are the formats GT has used for dates, tried in order by Parse.
+// The first is the one GT uses now.
+var Layouts = []string{
+	"January 2, 2006",
+	"Jan. 2, 2006",
+	"Jan 2, 2006",
+	"Monday, January 2, 2006",
+	"1/2/2006",
+	ISOLayout,
+}
+
+// Parse reads date in any of Layouts, ignoring surrounding space.
+func Parse(date string) (time.Time, error) {
+	date = strings.TrimSpace(date)
+	for _, layout := range Layouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("unknown date format: " + date)
+}
+
+// ISO converts date into YYYY-MM-DD. Dates that match none of Layouts are
+// returned unchanged.
+func ISO(date string) string {
+	t, err := Parse(date)
+	if err != nil {
+		return date
+	}
+	return t.Format(ISOLayout)
+}
+
+// padded writes the day and month of a layout with two digits, which GT
+// has also done.
+var padded = strings.NewReplacer("1/2/", "01/02/", " 2,", " 02,")
+
+// Forms returns every way date may have been stored: the day it names
+// written in each of Layouts, with and without zero padding. Dates that
+// match none of Layouts are returned alone.
+func Forms(date string) []string {
+	t, err := Parse(date)
+	if err != nil {
+		return []string{strings.TrimSpace(date)}
+	}
+
+	forms := []string{}
+	seen := make(map[string]bool)
+	for _, layout := range Layouts {
+		for _, l := range []string{layout, padded.Replace(layout)} {
+			if form := t.Format(l); !seen[form] {
+				seen[form] = true
+				forms = append(forms, form)
+			}
+		}
+	}
+	return forms
+}
@@ -0,0 +1,77 @@
+package gtdate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestISO(t *testing.T) {
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"September 21, 2020", "2020-09-21"},
+		{"Sept. 21, 2020", "Sept. 21, 2020"},
+		{"Sep. 21, 2020", "2020-09-21"},
+		{"Sep 21, 2020", "2020-09-21"},
+		{"Monday, September 21, 2020", "2020-09-21"},
+		{"9/21/2020", "2020-09-21"},
+		{"09/01/2020", "2020-09-01"},
+		{"2020-09-21", "2020-09-21"},
+		{"  August 3, 2020 \n", "2020-08-03"},
+		{"", ""},
+		{"not a date", "not a date"},
+		{"February 30, 2020", "February 30, 2020"},
+	}
+
+	for _, tt := range tests {
+		if got := ISO(tt.date); got != tt.want {
+			t.Errorf("ISO(%q) = %q, want %q", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		date    string
+		want    string
+		wantErr bool
+	}{
+		{"January 2, 2006", "2006-01-02", false},
+		{"1/2/2006", "2006-01-02", false},
+		{"2006-01-02", "2006-01-02", false},
+		{"2006/01/02", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.date)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, want error %v", tt.date, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.Format(ISOLayout) != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.date, got.Format(ISOLayout), tt.want)
+		}
+	}
+}
+
+func TestForms(t *testing.T) {
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"2020-09-01", "September 1, 2020|September 01, 2020|Sep. 1, 2020|Sep. 01, 2020|Sep 1, 2020|Sep 01, 2020|" +
+			"Tuesday, September 1, 2020|Tuesday, September 01, 2020|9/1/2020|09/01/2020|2020-09-01"},
+		{" 09/01/2020 ", "September 1, 2020|September 01, 2020|Sep. 1, 2020|Sep. 01, 2020|Sep 1, 2020|Sep 01, 2020|" +
+			"Tuesday, September 1, 2020|Tuesday, September 01, 2020|9/1/2020|09/01/2020|2020-09-01"},
+		{"2020-12-21", "December 21, 2020|Dec. 21, 2020|Dec 21, 2020|Monday, December 21, 2020|12/21/2020|2020-12-21"},
+		{" not a date ", "not a date"},
+	}
+
+	for _, tt := range tests {
+		if got := strings.Join(Forms(tt.date), "|"); got != tt.want {
+			t.Errorf("Forms(%q) = %q, want %q", tt.date, got, tt.want)
+		}
+	}
+}
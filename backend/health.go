@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// readyTimeout bounds each dependency check of /readyz.
+const readyTimeout = 2 * time.Second
+
+// ReadyData reports each readiness check as "ok" or the reason it failed.
+type ReadyData struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// healthz answers as long as the process is serving requests.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	writeData(w, http.StatusOK, map[string]string{"status": "ok"}, nil)
+}
+
+// readyz answers 503 unless Postgres and Redis are reachable and the
+// dataset has been refreshed recently, so that a load balancer stops
+// sending traffic to an instance serving stale data.
+func readyz(w http.ResponseWriter, r *http.Request) {
+	c, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	data := ReadyData{Ready: true, Checks: make(map[string]string)}
+	check := func(name string, err error) {
+		if err != nil {
+			data.Ready = false
+			data.Checks[name] = err.Error()
+			return
+		}
+		data.Checks[name] = "ok"
+	}
+
+	check("database", db.PingContext(c))
+	check("redis", rdb.Ping(c).Err())
+
+	// a refresh is attempted every interval, so missing two in a row means
+	// Postgres has been failing for a while
+	if age := time.Since(currentDataset().loadedAt); age > 2*refreshInterval() {
+		check("dataset", errStale(age))
+	} else {
+		check("dataset", nil)
+	}
+
+	status := http.StatusOK
+	if !data.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeData(w, status, data, nil)
+}
+
+type errStale time.Duration
+
+func (e errStale) Error() string {
+	return "last refreshed " + time.Duration(e).Round(time.Second).String() + " ago"
+}
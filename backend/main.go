@@ -1,24 +1,48 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/go-redis/redis/v8"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 )
 
+var rdb *redis.Client
 var db *sql.DB
 var conf tomlConfig
+var ctx = context.Background()
 
 type tomlConfig struct {
-	Database postgresCredentials
+	Redis     redisCredentials
+	Database  postgresCredentials
+	Dataset   datasetConfig
+	GraphQL   graphQLConfig
+	Server    serverConfig
+	GRPC      grpcConfig
+	RateLimit rateLimitConfig
+}
+
+type redisCredentials struct {
+	Address  string
+	Password string
+	DB       int
 }
 
 type postgresCredentials struct {
@@ -29,7 +53,9 @@ type postgresCredentials struct {
 	DBName   string
 }
 
-func init() {
+// setup loads config.toml and connects to Redis and Postgres. It is called
+// from main rather than init so that tests can run without either.
+func setup() {
 	if _, err := toml.DecodeFile("config.toml", &conf); err != nil {
 		log.Fatalf("error: could not parse configuration %v\n", err)
 	}
@@ -38,7 +64,17 @@ func init() {
 		"sslmode=disable", conf.Database.Host, conf.Database.Port,
 		conf.Database.User, conf.Database.Password, conf.Database.DBName)
 
-	var err error
+	rdb = redis.NewClient(&redis.Options{
+		Addr:     conf.Redis.Address,
+		Password: conf.Redis.Password,
+		DB:       conf.Redis.DB,
+	})
+
+	_, err := rdb.Ping(ctx).Result()
+	if err != nil {
+		log.Fatalf("error: could not make connection with redis: %v\n", err)
+	}
+
 	db, err = sql.Open("postgres", pSqlInfo)
 	if err != nil {
 		log.Fatal(err)
@@ -48,17 +84,136 @@ func init() {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	if err := migrate(); err != nil {
+		log.Fatalf("error: could not migrate database: %v\n", err)
+	}
 }
 
 func main() {
+	setup()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "apikey":
+			runKeyCommand(os.Args[2:])
+			return
+		case "subscription":
+			runSubscriptionCommand(os.Args[2:])
+			return
+		case "deadletter":
+			runDeadLetterCommand(os.Args[2:])
+			return
+		}
+	}
+
+	if err := loadKeys(); err != nil {
+		log.Fatalf("error: could not load api keys: %v\n", err)
+	}
+	go watchKeys()
+
+	if err := refreshDataset(); err != nil {
+		log.Fatalf("error: could not load dataset: %v\n", err)
+	}
+	go watchDataset()
+
+	compressor := middleware.NewCompressor(5)
+	compressor.SetEncoder("br", encoderBrotli)
+
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
+	r.Use(instrument)
+	r.Use(compressor.Handler)
+
+	// probes and scrapes are neither rate limited nor served under /gt-jpj,
+	// so the public proxy does not expose them
+	r.Get("/healthz", healthz)
+	r.Get("/readyz", readyz)
+	r.Handle("/metrics", promhttp.Handler())
+
+	r.Group(func(r chi.Router) {
+		if !conf.RateLimit.Disabled {
+			r.Use(rateLimit)
+		}
+
+		r.Get("/gt-jpj", homePage)
+		r.With(cacheDataset).Get("/gt-jpj/cases", getAllCases)
+		r.With(cacheDataset).Get("/gt-jpj/testing", getAllSurveys)
+		r.With(cacheDataset).Get("/gt-jpj/feed.atom", getAtomFeed)
+		r.With(cacheDataset).Get("/gt-jpj/feed.rss", getRSSFeed)
+		r.With(cacheDataset).Get("/gt-jpj/badge/{metric}.svg", getBadge)
+		r.Get("/gt-jpj/openapi.json", getOpenAPI)
+		r.Get("/gt-jpj/events", streamEvents)
+		r.Get("/gt-jpj/ws", serveWebSocket)
+		r.Get("/gt-jpj/graphql", serveGraphQL)
+		r.Post("/gt-jpj/graphql", serveGraphQL)
+
+		r.Route("/gt-jpj/v2", v2Routes)
+		r.Route("/gt-jpj/admin", adminRoutes)
+		r.Route("/gt-jpj/subscriptions", subscriptionRoutes)
+	})
+
+	srv := newServer(r)
+	lis, err := listen()
+	if err != nil {
+		log.Fatalf("error: could not listen: %v\n", err)
+	}
+
+	var grpcServer *grpc.Server
+	var grpcLis net.Listener
+	if !conf.GRPC.Disabled {
+		if grpcServer, grpcLis, err = newGRPCServer(); err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+	}
 
-	r.Get("/gt-jpj", homePage)
-	r.Get("/gt-jpj/cases", getAllCases)
-	r.Get("/gt-jpj/testing", getAllSurveys)
+	errs := make(chan error, 2)
+	go func() {
+		if err := serve(srv, lis); err != nil {
+			errs <- fmt.Errorf("http server stopped: %v", err)
+		}
+	}()
+	if grpcServer != nil {
+		go func() {
+			if err := grpcServer.Serve(grpcLis); err != nil {
+				errs <- fmt.Errorf("grpc server stopped: %v", err)
+			}
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	var exitErr error
+	select {
+	case sig := <-stop:
+		log.Printf("received %s, shutting down\n", sig)
+	case exitErr = <-errs:
+		log.Printf("error: %v\n", exitErr)
+	}
+
+	// end streams first, since Shutdown waits for every request to finish
+	beginShutdown()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("error: could not drain connections: %v\n", err)
+	}
+	if grpcServer != nil {
+		stopGRPC(shutdownCtx, grpcServer)
+	}
+	cancel()
 
-	http.ListenAndServe(":3000", r)
+	if err := rdb.Close(); err != nil {
+		log.Printf("error: could not close redis: %v\n", err)
+	}
+	if err := db.Close(); err != nil {
+		log.Printf("error: could not close database: %v\n", err)
+	}
+
+	if exitErr != nil {
+		os.Exit(1)
+	}
 }
 
 func homePage(w http.ResponseWriter, r *http.Request) {
@@ -71,10 +226,12 @@ func homePage(w http.ResponseWriter, r *http.Request) {
 }
 
 type CasesRow struct {
-	ID       int    `json:"id"`
-	Date     string `json:"date"`
-	Reported int    `json:"reported"`
-	Total    int    `json:"total"`
+	ID        int    `json:"id"`
+	Date      string `json:"date"`
+	Reported  int    `json:"reported"`
+	Total     int    `json:"total"`
+	Corrected bool   `json:"-"`
+	Hidden    bool   `json:"-"`
 }
 
 type CaseResponse struct {
@@ -87,56 +244,56 @@ type StringResponse struct {
 	Code    int    `json:"status_code"`
 }
 
-func getAllCases(w http.ResponseWriter, r *http.Request) {
-	statement := `SELECT * FROM cases`
+// queryCases returns every stored row of cases in the order Postgres
+// returns them, which is the order the legacy endpoint has always served.
+func queryCases() ([]CasesRow, error) {
+	defer observeQuery("cases", time.Now())
+
+	statement := `SELECT id, date, reported, total, hidden, corrected_at IS NOT NULL FROM cases`
 	rows, err := db.Query(statement)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(StringResponse{
-			Code:    500,
-			Payload: "Internal Server Error",
-		})
-		return
+		return nil, err
 	}
 
-	caseData := make([]CasesRow, 0)
 	defer rows.Close()
 
+	caseData := make([]CasesRow, 0)
 	for rows.Next() {
 		day := CasesRow{}
-
-		if err := rows.Scan(&day.ID, &day.Date, &day.Reported, &day.Total); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(StringResponse{
-				Code:    500,
-				Payload: "Internal Server Error",
-			})
-			return
+		if err := rows.Scan(&day.ID, &day.Date, &day.Reported, &day.Total, &day.Hidden, &day.Corrected); err != nil {
+			return nil, err
 		}
 
 		day.Date = strings.TrimSpace(day.Date)
-
 		caseData = append(caseData, day)
 	}
 
-	uniqueCaseData := make([]CasesRow, 0)
+	return caseData, rows.Err()
+}
+
+// uniqueCases deduplicates rows on date, keeping the first row seen for
+// each. Days whose first row an admin has hidden are left out.
+func uniqueCases(rows []CasesRow) []CasesRow {
+	caseData := make([]CasesRow, 0)
 	uniqueMap := make(map[string]bool)
 
-	for i, day := range caseData {
-		if uniqueMap[day.Date] == false {
-			uniqueMap[day.Date] = true
-			caseData[i].ID = len(uniqueMap)
-			uniqueCaseData = append(uniqueCaseData, caseData[i])
+	for _, day := range rows {
+		// compare in ISO 8601 so that days entered through the admin API
+		// match those scraped from GT
+		if key := gtdate.ISO(day.Date); uniqueMap[key] == false {
+			uniqueMap[key] = true
+			if !day.Hidden {
+				caseData = append(caseData, day)
+			}
 		}
 	}
 
-	data := CaseResponse{
-		Payload: uniqueCaseData,
-		Code:    200,
-	}
-
-	json.NewEncoder(w).Encode(data)
+	return caseData
+}
 
+func getAllCases(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(requestDataset(r).legacyCases)
 }
 
 type SurveysRow struct {
@@ -144,6 +301,8 @@ type SurveysRow struct {
 	Date         string `json:"date"`
 	Positive     int    `json:"positive"`
 	Administered int    `json:"administered"`
+	Corrected    bool   `json:"-"`
+	Hidden       bool   `json:"-"`
 }
 
 type SurveyResponse struct {
@@ -151,54 +310,51 @@ type SurveyResponse struct {
 	Code    int          `json:"status_code"`
 }
 
-func getAllSurveys(w http.ResponseWriter, r *http.Request) {
-	statement := `SELECT * FROM surveys`
+// querySurveys returns every stored row of surveys in the same way as
+// queryCases.
+func querySurveys() ([]SurveysRow, error) {
+	defer observeQuery("surveys", time.Now())
+
+	statement := `SELECT id, date, positive, administered, hidden, corrected_at IS NOT NULL FROM surveys`
 	rows, err := db.Query(statement)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(StringResponse{
-			Code:    500,
-			Payload: "Internal Server Error",
-		})
-		return
+		return nil, err
 	}
 
-	surveyData := make([]SurveysRow, 0)
 	defer rows.Close()
 
+	surveyData := make([]SurveysRow, 0)
 	for rows.Next() {
 		day := SurveysRow{}
-
-		if err := rows.Scan(&day.ID, &day.Date, &day.Positive, &day.Administered); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(StringResponse{
-				Code:    500,
-				Payload: "Internal Server Error",
-			})
-			return
+		if err := rows.Scan(&day.ID, &day.Date, &day.Positive, &day.Administered, &day.Hidden, &day.Corrected); err != nil {
+			return nil, err
 		}
 
 		day.Date = strings.TrimSpace(day.Date)
-
 		surveyData = append(surveyData, day)
 	}
 
-	uniqueSurveyData := make([]SurveysRow, 0)
+	return surveyData, rows.Err()
+}
+
+// uniqueSurveys deduplicates rows in the same way as uniqueCases.
+func uniqueSurveys(rows []SurveysRow) []SurveysRow {
+	surveyData := make([]SurveysRow, 0)
 	uniqueMap := make(map[string]bool)
 
-	for i, day := range surveyData {
-		if uniqueMap[day.Date] == false {
-			uniqueMap[day.Date] = true
-			surveyData[i].ID = len(uniqueMap)
-			uniqueSurveyData = append(uniqueSurveyData, surveyData[i])
+	for _, day := range rows {
+		if key := gtdate.ISO(day.Date); uniqueMap[key] == false {
+			uniqueMap[key] = true
+			if !day.Hidden {
+				surveyData = append(surveyData, day)
+			}
 		}
 	}
 
-	data := SurveyResponse{
-		Payload: uniqueSurveyData,
-		Code:    200,
-	}
-
-	json.NewEncoder(w).Encode(data)
+	return surveyData
+}
 
+func getAllSurveys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(requestDataset(r).legacySurveys)
 }
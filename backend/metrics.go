@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gtjpj_http_requests_total",
+		Help: "HTTP requests served, by route pattern, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gtjpj_http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests, by route pattern and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gtjpj_db_query_duration_seconds",
+		Help:    "Time taken by Postgres queries, by query.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"query"})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "gtjpj_data_age_seconds",
+		Help:        "Time since the latest row of the dataset was scraped.",
+		ConstLabels: prometheus.Labels{"dataset": "cases"},
+	}, func() float64 {
+		return dataAge(currentDataset().casesScrapedAt)
+	})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "gtjpj_data_age_seconds",
+		Help:        "Time since the latest row of the dataset was scraped.",
+		ConstLabels: prometheus.Labels{"dataset": "surveys"},
+	}, func() float64 {
+		return dataAge(currentDataset().surveysScrapedAt)
+	})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "gtjpj_dataset_age_seconds",
+		Help: "Time since the in-memory dataset was last reloaded from Postgres.",
+	}, func() float64 {
+		return time.Since(currentDataset().loadedAt).Seconds()
+	})
+)
+
+func dataAge(scrapedAt time.Time) float64 {
+	if scrapedAt.IsZero() {
+		return 0
+	}
+	return time.Since(scrapedAt).Seconds()
+}
+
+// observeQuery records the duration of a query started at start. Use it as
+// `defer observeQuery("name", time.Now())`.
+func observeQuery(query string, start time.Time) {
+	dbDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
+
+// instrument counts and times every request by the route pattern it
+// matched, so that /v2/cases/{date} is one series rather than one per date.
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			// the connection was hijacked by the WebSocket upgrade
+			status = http.StatusSwitchingProtocols
+		}
+
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
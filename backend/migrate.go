@@ -0,0 +1,112 @@
+package main
+
+// migrations bring an existing database up to the schema the backend and
+// scrapers expect. They run in order on every start, so each statement must
+// be safe to repeat.
+var migrations = []string{
+	`ALTER TABLE cases ADD COLUMN IF NOT EXISTS scraped_at TIMESTAMPTZ NOT NULL DEFAULT now()`,
+	`ALTER TABLE surveys ADD COLUMN IF NOT EXISTS scraped_at TIMESTAMPTZ NOT NULL DEFAULT now()`,
+	`CREATE TABLE IF NOT EXISTS events (
+		id         BIGSERIAL PRIMARY KEY,
+		kind       TEXT NOT NULL,
+		date       TEXT NOT NULL,
+		payload    JSONB NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id         SERIAL PRIMARY KEY,
+		name       TEXT NOT NULL,
+		key_hash   TEXT NOT NULL UNIQUE,
+		rate       INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		revoked_at TIMESTAMPTZ
+	)`,
+	`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS admin BOOLEAN NOT NULL DEFAULT false`,
+	`ALTER TABLE cases ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT false`,
+	`ALTER TABLE cases ADD COLUMN IF NOT EXISTS corrected_at TIMESTAMPTZ`,
+	`ALTER TABLE surveys ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT false`,
+	`ALTER TABLE surveys ADD COLUMN IF NOT EXISTS corrected_at TIMESTAMPTZ`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id         BIGSERIAL PRIMARY KEY,
+		key_id     INTEGER NOT NULL REFERENCES api_keys (id),
+		actor      TEXT NOT NULL,
+		action     TEXT NOT NULL,
+		dataset    TEXT NOT NULL,
+		date       TEXT NOT NULL,
+		before     JSONB,
+		after      JSONB,
+		reason     TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id          SERIAL PRIMARY KEY,
+		key_id      INTEGER REFERENCES api_keys (id),
+		name        TEXT NOT NULL,
+		url         TEXT NOT NULL UNIQUE,
+		datasets    TEXT[] NOT NULL,
+		alert_types TEXT[] NOT NULL,
+		paused      BOOLEAN NOT NULL DEFAULT false,
+		created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+		verified_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'discord'`,
+	`ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT 'en'`,
+	`CREATE TABLE IF NOT EXISTS digests (
+		week    DATE PRIMARY KEY,
+		sent_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`ALTER TABLE digests ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'sent'`,
+	`ALTER TABLE digests ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ NOT NULL DEFAULT now()`,
+	`ALTER TABLE digests ALTER COLUMN sent_at DROP NOT NULL`,
+	`ALTER TABLE digests ALTER COLUMN sent_at DROP DEFAULT`,
+	`CREATE TABLE IF NOT EXISTS deliveries (
+		id              BIGSERIAL PRIMARY KEY,
+		subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+		template        TEXT NOT NULL,
+		delivered       BOOLEAN NOT NULL,
+		attempts        INTEGER NOT NULL,
+		status_code     INTEGER,
+		error           TEXT,
+		created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS deliveries_subscription_idx ON deliveries (subscription_id, created_at)`,
+	`CREATE TABLE IF NOT EXISTS dead_letters (
+		id              BIGSERIAL PRIMARY KEY,
+		subscription_id INTEGER REFERENCES webhook_subscriptions (id) ON DELETE SET NULL,
+		type            TEXT NOT NULL,
+		url             TEXT NOT NULL,
+		template        TEXT NOT NULL,
+		message         JSONB NOT NULL,
+		error           TEXT NOT NULL,
+		attempts        INTEGER NOT NULL,
+		created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+		redelivered_at  TIMESTAMPTZ
+	)`,
+	`CREATE TABLE IF NOT EXISTS combined_posts (
+		day        DATE NOT NULL,
+		dataset    TEXT NOT NULL,
+		data       JSONB NOT NULL,
+		sent       BOOLEAN NOT NULL DEFAULT false,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (day, dataset)
+	)`,
+	`CREATE TABLE IF NOT EXISTS sent_messages (
+		subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+		dataset         TEXT NOT NULL,
+		date            TEXT NOT NULL,
+		template        TEXT NOT NULL,
+		message_id      TEXT NOT NULL,
+		data            JSONB NOT NULL,
+		created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (subscription_id, dataset, date)
+	)`,
+}
+
+func migrate() error {
+	for _, statement := range migrations {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// CombinedTemplate is the template of the post combining both datasets.
+const CombinedTemplate = "combined"
+
+// Combined configures posting a single daily message for both datasets to
+// the subscriptions that receive the daily posts of both. The first
+// scraper to publish on a day waits for the other one; if it has not
+// published by Deadline, the posts go out separately as they would without
+// Combined. The scrapers check on every run, so the fallback is sent on
+// the first run after Deadline.
+type Combined struct {
+	Enabled  bool
+	Deadline string // time of day as HH:MM, defaults to 20:00
+	Timezone string // of Deadline and the day, defaults to America/New_York
+
+	deadline time.Duration
+	location *time.Location
+}
+
+// Check validates c and fills in its defaults.
+func (c *Combined) Check() error {
+	if c.Deadline == "" {
+		c.Deadline = "20:00"
+	}
+	if c.Timezone == "" {
+		c.Timezone = "America/New_York"
+	}
+
+	t, err := time.Parse("15:04", c.Deadline)
+	if err != nil {
+		return fmt.Errorf("Deadline must be a time of day as HH:MM")
+	}
+	c.deadline = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+
+	if c.location, err = time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("unknown Timezone %s", c.Timezone)
+	}
+	return nil
+}
+
+// today is the current day where the deadline is kept.
+func (c Combined) today() string {
+	return time.Now().In(c.location).Format("2006-01-02")
+}
+
+// due reports whether the deadline of day has passed at now.
+func (c Combined) due(day, now time.Time) bool {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, c.location)
+	return now.After(start.Add(c.deadline))
+}
+
+// ready reports whether the posts of day are sent at now, given how many
+// datasets are waiting and already sent: when both are waiting, when the
+// other went out without them, or when the deadline has passed.
+func (c Combined) ready(day time.Time, waiting, sent int, now time.Time) bool {
+	return waiting >= len(Keys) || sent > 0 || c.due(day, now)
+}
+
+// CombinedSubscribers returns the active destinations of the daily posts
+// of both datasets.
+func CombinedSubscribers(db *sql.DB) ([]Destination, error) {
+	rows, err := db.Query(`SELECT id, type, url, locale FROM webhook_subscriptions
+		WHERE NOT paused AND 'cases' = ANY(datasets) AND 'surveys' = ANY(datasets) AND 'daily' = ANY(alert_types)
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDestinations(rows)
+}
+
+// Deliver sends the daily post of data.Dataset, rendered from the template
+// name, right away to the destinations in list that only receive that
+// dataset, and holds it back for the combined post to the others.
+func (c Combined) Deliver(ctx context.Context, db *sql.DB, templates *Templates, list []Destination, name string, data Data) {
+	combined, err := CombinedSubscribers(db)
+	if err != nil {
+		log.Println(err)
+		Deliver(ctx, db, templates, list, name, data)
+		return
+	}
+
+	waiting := make(map[int]bool)
+	for _, d := range combined {
+		waiting[d.ID] = true
+	}
+	alone := []Destination{}
+	for _, d := range list {
+		if !waiting[d.ID] {
+			alone = append(alone, d)
+		}
+	}
+	Deliver(ctx, db, templates, alone, name, data)
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	_, err = db.Exec(`INSERT INTO combined_posts (day, dataset, data) VALUES ($1, $2, $3)
+		ON CONFLICT (day, dataset) DO UPDATE SET data = EXCLUDED.data WHERE NOT combined_posts.sent`,
+		c.today(), data.Dataset, payload)
+	if err != nil {
+		// better separately than not at all
+		log.Println(err)
+		Deliver(ctx, db, templates, combined, name, data)
+		return
+	}
+
+	c.Flush(ctx, db, templates)
+}
+
+// Flush sends the combined post of every day both datasets are held back
+// for, and the held back posts separately once their day is past the
+// deadline or the other dataset was already sent without them.
+func (c Combined) Flush(ctx context.Context, db *sql.DB, templates *Templates) {
+	rows, err := db.Query(`SELECT day, dataset, sent FROM combined_posts
+		WHERE day IN (SELECT day FROM combined_posts WHERE NOT sent)`)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	type pending struct {
+		day           time.Time
+		waiting, sent int
+	}
+	days := make(map[string]*pending)
+	for rows.Next() {
+		var day time.Time
+		var dataset string
+		var sent bool
+		if err := rows.Scan(&day, &dataset, &sent); err != nil {
+			rows.Close()
+			log.Println(err)
+			return
+		}
+
+		key := day.Format("2006-01-02")
+		if days[key] == nil {
+			days[key] = &pending{day: day}
+		}
+		if sent {
+			days[key].sent++
+		} else {
+			days[key].waiting++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return
+	}
+
+	keys := make([]string, 0, len(days))
+	for key := range days {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		p := days[key]
+		if !c.ready(p.day, p.waiting, p.sent, time.Now()) {
+			continue
+		}
+		if err := c.send(ctx, db, templates, key); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// send claims the posts held back for day and delivers them, combined if
+// both datasets are there.
+func (c Combined) send(ctx context.Context, db *sql.DB, templates *Templates, day string) error {
+	claimed, err := claim(db, day)
+	if err != nil || len(claimed) == 0 {
+		return err
+	}
+
+	list, err := CombinedSubscribers(db)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range combine(claimed) {
+		Deliver(ctx, db, templates, list, p.name, p.data)
+	}
+	return nil
+}
+
+// claim marks the posts held back for day as sent and returns them by
+// dataset. Only one scraper gets the rows, however many flush at once.
+func claim(db *sql.DB, day string) (map[string]Data, error) {
+	rows, err := db.Query(`UPDATE combined_posts SET sent = true
+		WHERE day = $1 AND NOT sent
+		RETURNING dataset, data`, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claimed := make(map[string]Data)
+	for rows.Next() {
+		var dataset string
+		var payload []byte
+		if err := rows.Scan(&dataset, &payload); err != nil {
+			return nil, err
+		}
+		var data Data
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return nil, err
+		}
+		claimed[dataset] = data
+	}
+	return claimed, rows.Err()
+}
+
+// heldPost is a post to deliver to the subscribers of both datasets.
+type heldPost struct {
+	name string
+	data Data
+}
+
+// combine returns the posts to deliver for what was claimed: the combined
+// post if both datasets are there, otherwise the daily post of each.
+func combine(claimed map[string]Data) []heldPost {
+	cases, hasCases := claimed["cases"]
+	surveys, hasSurveys := claimed["surveys"]
+	if hasCases && hasSurveys {
+		data := cases
+		data.Dataset = CombinedTemplate
+		data.Cases, data.Surveys = &cases, &surveys
+		return []heldPost{{CombinedTemplate, data}}
+	}
+
+	posts := []heldPost{}
+	for _, dataset := range []string{"cases", "surveys"} {
+		if data, ok := claimed[dataset]; ok {
+			posts = append(posts, heldPost{dataset + ".daily", data})
+		}
+	}
+	return posts
+}
@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/testenv"
+)
+
+func TestCombinedReady(t *testing.T) {
+	c := Combined{Deadline: "20:00", Timezone: "America/New_York"}
+	if err := c.Check(); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2020, 9, 21, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2020, 9, 21, 19, 59, 0, 0, c.location)
+	after := time.Date(2020, 9, 21, 20, 1, 0, 0, c.location)
+
+	tests := []struct {
+		name          string
+		waiting, sent int
+		now           time.Time
+		want          bool
+	}{
+		{"one waiting before the deadline", 1, 0, before, false},
+		{"both waiting", 2, 0, before, true},
+		{"one waiting after the deadline", 1, 0, after, true},
+		{"other already sent", 1, 1, before, true},
+		{"next day", 1, 0, before.AddDate(0, 0, 1), true},
+	}
+
+	for _, tt := range tests {
+		if got := c.ready(day, tt.waiting, tt.sent, tt.now); got != tt.want {
+			t.Errorf("%s: ready = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCombine(t *testing.T) {
+	cases := Data{Dataset: "cases", Date: "September 21, 2020", Values: map[string]int{"reported": 5}}
+	surveys := Data{Dataset: "surveys", Date: "September 21, 2020", Values: map[string]int{"positive": 1}}
+
+	combined := cases
+	combined.Dataset = CombinedTemplate
+	combined.Cases, combined.Surveys = &cases, &surveys
+
+	tests := []struct {
+		name    string
+		claimed map[string]Data
+		want    []heldPost
+	}{
+		{"nothing", map[string]Data{}, []heldPost{}},
+		{"both", map[string]Data{"cases": cases, "surveys": surveys}, []heldPost{{CombinedTemplate, combined}}},
+		{"cases alone", map[string]Data{"cases": cases}, []heldPost{{"cases.daily", cases}}},
+		{"surveys alone", map[string]Data{"surveys": surveys}, []heldPost{{"surveys.daily", surveys}}},
+	}
+
+	for _, tt := range tests {
+		if got := combine(tt.claimed); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: combine = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClaim(t *testing.T) {
+	db := testenv.Database(t)
+	_, err := db.Exec(`CREATE TABLE combined_posts (
+		day     DATE NOT NULL,
+		dataset TEXT NOT NULL,
+		data    JSONB NOT NULL,
+		sent    BOOLEAN NOT NULL DEFAULT false,
+		PRIMARY KEY (day, dataset)
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hold := func(day, dataset string, sent bool) {
+		payload, _ := json.Marshal(Data{Dataset: dataset})
+		if _, err := db.Exec(`INSERT INTO combined_posts (day, dataset, data, sent) VALUES ($1, $2, $3, $4)`,
+			day, dataset, payload, sent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hold("2020-09-21", "cases", false)
+	hold("2020-09-21", "surveys", false)
+	hold("2020-09-22", "cases", true)
+	hold("2020-09-22", "surveys", false)
+
+	tests := []struct {
+		day  string
+		want []string
+	}{
+		{"2020-09-21", []string{"cases", "surveys"}},
+		{"2020-09-21", []string{}},
+		{"2020-09-22", []string{"surveys"}},
+		{"2020-09-23", []string{}},
+	}
+
+	for _, tt := range tests {
+		claimed, err := claim(db, tt.day)
+		if err != nil {
+			t.Fatalf("claim(%s): %v", tt.day, err)
+		}
+		got := []string{}
+		for _, dataset := range []string{"cases", "surveys"} {
+			if data, ok := claimed[dataset]; ok {
+				if data.Dataset != dataset {
+					t.Errorf("claim(%s): %s holds %s", tt.day, dataset, data.Dataset)
+				}
+				got = append(got, dataset)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("claim(%s) = %v, want %v", tt.day, got, tt.want)
+		}
+	}
+}
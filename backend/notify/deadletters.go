@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DeadLetter is a message that could not be delivered.
+type DeadLetter struct {
+	ID            int
+	Subscription  sql.NullInt64 // null once the subscription is deleted
+	Destination   Destination
+	Template      string
+	Message       Message
+	Error         string
+	Attempts      int
+	CreatedAt     time.Time
+	RedeliveredAt sql.NullTime
+}
+
+const deadLetterColumns = `id, subscription_id, type, url, template, message, error, attempts, created_at, redelivered_at`
+
+func scanDeadLetter(row interface{ Scan(...interface{}) error }) (DeadLetter, error) {
+	var l DeadLetter
+	var payload []byte
+	err := row.Scan(&l.ID, &l.Subscription, &l.Destination.Type, &l.Destination.URL, &l.Template,
+		&payload, &l.Error, &l.Attempts, &l.CreatedAt, &l.RedeliveredAt)
+	if err != nil {
+		return l, err
+	}
+	l.Destination.ID = int(l.Subscription.Int64)
+	return l, json.Unmarshal(payload, &l.Message)
+}
+
+// DeadLetters lists the dead letters not yet redelivered, oldest first,
+// or every one of them if all is set.
+func DeadLetters(db *sql.DB, all bool) ([]DeadLetter, error) {
+	rows, err := db.Query(`SELECT `+deadLetterColumns+` FROM dead_letters
+		WHERE $1 OR redelivered_at IS NULL
+		ORDER BY id`, all)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := []DeadLetter{}
+	for rows.Next() {
+		l, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, l)
+	}
+
+	return list, rows.Err()
+}
+
+// GetDeadLetter returns the dead letter id.
+func GetDeadLetter(db *sql.DB, id int) (DeadLetter, error) {
+	l, err := scanDeadLetter(db.QueryRow(`SELECT `+deadLetterColumns+` FROM dead_letters WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return l, fmt.Errorf("no dead letter with id %d", id)
+	}
+	return l, err
+}
+
+// Redeliver sends the dead letter id again. It is marked as redelivered
+// if that succeeds, and keeps the latest error otherwise.
+func Redeliver(ctx context.Context, db *sql.DB, id int) error {
+	l, err := GetDeadLetter(db, id)
+	if err != nil {
+		return err
+	}
+	if l.RedeliveredAt.Valid {
+		return fmt.Errorf("dead letter %d was already redelivered", id)
+	}
+	if err := CheckURL(l.Destination.Type, l.Destination.URL); err != nil {
+		return err
+	}
+
+	_, attempts, sendErr := send(ctx, l.Destination, l.Message)
+	if l.Subscription.Valid {
+		logDelivery(db, l.Destination.ID, l.Template, attempts, sendErr)
+	}
+
+	if sendErr != nil {
+		_, err = db.Exec(`UPDATE dead_letters SET error = $2, attempts = attempts + $3 WHERE id = $1`,
+			id, sendErr.Error(), attempts)
+		if err != nil {
+			return err
+		}
+		return sendErr
+	}
+
+	_, err = db.Exec(`UPDATE dead_letters SET redelivered_at = now() WHERE id = $1`, id)
+	return err
+}
+
+// DiscardDeadLetter deletes the dead letter id without delivering it.
+func DiscardDeadLetter(db *sql.DB, id int) error {
+	res, err := db.Exec(`DELETE FROM dead_letters WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no dead letter with id %d", id)
+	}
+	return nil
+}
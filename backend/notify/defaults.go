@@ -0,0 +1,227 @@
+package notify
+
+// defaultTemplates reproduce the embeds the scrapers have always posted,
+// with their labels translated by T. They double as examples for template
+// files.
+var defaultTemplates = map[string]string{
+	"cases.daily": `
+Username = "GT Stamps Health Services"
+AvatarURL = "https://img.aditya.diwakar.io/stamps.png"
+Title = "{{T \"[%s] GT COVID-19 Update\" (date .Date)}}{{if .Revised}} {{T \"(Revised)\"}}{{end}}"
+URL = "{{.Link}}"
+Color = 11772777
+Image = "{{with .Chart}}attachment://{{.Name}}{{end}}"
+Footer = "{{T \"Made with ❤️ by Aditya Diwakar\"}}{{range .Footnotes}} · {{.}}{{end}}"
+
+[[Fields]]
+Name = "{{T \"Reported Today\"}}"
+Value = "{{number .Values.reported}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Total\"}}"
+Value = "{{number .Values.total}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"7/30 Day MA\"}}"
+Value = "{{with .Averages}}{{decimal 1 .seven}}/{{decimal 1 .thirty}}{{end}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Last 30 Days\"}}"
+Value = "{{if not .Chart}}{{sparkline .Series}}{{end}}"
+`,
+
+	"cases.revision": `
+Username = "GT Stamps Health Services"
+AvatarURL = "https://img.aditya.diwakar.io/stamps.png"
+Title = "{{T \"[%s] GT COVID-19 Update\" (date .Date)}} {{T \"(Revised)\"}}"
+URL = "{{.Link}}"
+Color = 11772777
+Footer = "{{T \"Made with ❤️ by Aditya Diwakar\"}}"
+
+[[Fields]]
+Name = "{{T \"Reported Today\"}}"
+Value = "{{number .Previous.reported}} → {{number .Values.reported}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Total\"}}"
+Value = "{{number .Previous.total}} → {{number .Values.total}}"
+Inline = true
+`,
+
+	"cases.alert": `
+Username = "GT Stamps Health Services"
+AvatarURL = "https://img.aditya.diwakar.io/stamps.png"
+Title = "{{T \"[%s] GT COVID-19 Alert: %s\" (date .Date) .Alert.Rule}}"
+URL = "{{.Link}}"
+Color = "{{.Alert.Color}}"
+Text = "{{.Alert.Description}}"
+Footer = "{{T \"Severity: %s\" (T .Alert.Severity)}} · {{T \"Made with ❤️ by Aditya Diwakar\"}}"
+
+[[Fields]]
+Name = "{{T \"Reported Today\"}}"
+Value = "{{number .Values.reported}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Total\"}}"
+Value = "{{number .Values.total}}"
+Inline = true
+`,
+
+	"surveys.daily": `
+Username = "GT Stamps Health Services"
+AvatarURL = "https://img.aditya.diwakar.io/stamps.png"
+Title = "{{T \"[%s] Surveillance Testing Program Results\" (date .Date)}} {{if .Revised}}{{T \"(Revised)\"}}{{end}}"
+URL = "{{.Link}}"
+Color = 11772777
+Footer = "{{T \"Made with ❤️ by Aditya Diwakar\"}}{{range .Footnotes}} · {{.}}{{end}}"
+
+[[Fields]]
+Name = "{{T \"Tested Positive (All Time)\"}}"
+Value = "{{number .Values.positive}} ({{signed .Deltas.positive}})"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Tests Administered\"}}"
+Value = "{{number .Values.administered}} ({{signed .Deltas.administered}})"
+Inline = true
+`,
+
+	"surveys.revision": `
+Username = "GT Stamps Health Services"
+AvatarURL = "https://img.aditya.diwakar.io/stamps.png"
+Title = "{{T \"[%s] Surveillance Testing Program Results\" (date .Date)}} {{T \"(Revised)\"}}"
+URL = "{{.Link}}"
+Color = 11772777
+Footer = "{{T \"Made with ❤️ by Aditya Diwakar\"}}"
+
+[[Fields]]
+Name = "{{T \"Tested Positive (All Time)\"}}"
+Value = "{{number .Previous.positive}} → {{number .Values.positive}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Tests Administered\"}}"
+Value = "{{number .Previous.administered}} → {{number .Values.administered}}"
+Inline = true
+`,
+
+	"surveys.alert": `
+Username = "GT Stamps Health Services"
+AvatarURL = "https://img.aditya.diwakar.io/stamps.png"
+Title = "{{T \"[%s] Surveillance Testing Alert: %s\" (date .Date) .Alert.Rule}}"
+URL = "{{.Link}}"
+Color = "{{.Alert.Color}}"
+Text = "{{.Alert.Description}}"
+Footer = "{{T \"Severity: %s\" (T .Alert.Severity)}} · {{T \"Made with ❤️ by Aditya Diwakar\"}}"
+
+[[Fields]]
+Name = "{{T \"Tested Positive (All Time)\"}}"
+Value = "{{number .Values.positive}} ({{signed .Deltas.positive}})"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Tests Administered\"}}"
+Value = "{{number .Values.administered}} ({{signed .Deltas.administered}})"
+Inline = true
+`,
+
+	"combined": `
+Username = "GT Stamps Health Services"
+AvatarURL = "https://img.aditya.diwakar.io/stamps.png"
+Title = "{{T \"[%s] GT COVID-19 Daily Update\" (date .Date)}}{{if .Revised}} {{T \"(Revised)\"}}{{end}}"
+URL = "{{.Link}}"
+Color = 11772777
+Text = "{{with .Cases}}{{T \"%s cases reported\" (number .Values.reported)}}{{end}}{{with .Surveys}}{{T \", and %s of %s surveillance tests positive\" (number .Deltas.positive) (number .Deltas.administered)}}{{if .Deltas.administered}} ({{decimal 2 .Positivity}}%){{end}}{{end}}{{T \".\"}}"
+Image = "{{with .Chart}}attachment://{{.Name}}{{end}}"
+Footer = "{{T \"Made with ❤️ by Aditya Diwakar\"}}{{range .Cases.Footnotes}} · {{.}}{{end}}{{range .Surveys.Footnotes}} · {{.}}{{end}}"
+
+[[Fields]]
+Name = "{{T \"Reported Today\"}}"
+Value = "{{number .Cases.Values.reported}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Total\"}}"
+Value = "{{number .Cases.Values.total}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"7/30 Day MA\"}}"
+Value = "{{with .Cases.Averages}}{{decimal 1 .seven}}/{{decimal 1 .thirty}}{{end}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Tested Positive (All Time)\"}}"
+Value = "{{number .Surveys.Values.positive}} ({{signed .Surveys.Deltas.positive}})"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Tests Administered\"}}"
+Value = "{{number .Surveys.Values.administered}} ({{signed .Surveys.Deltas.administered}})"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Last 30 Days\"}}"
+Value = "{{if not .Chart}}{{sparkline .Series}}{{end}}"
+`,
+
+	"digest": `
+Username = "GT Stamps Health Services"
+AvatarURL = "https://img.aditya.diwakar.io/stamps.png"
+Title = "{{T \"[%s – %s] GT COVID-19 Weekly Digest\" (date .Digest.Start) (date .Digest.End)}}"
+URL = "{{or .Digest.Chart .Link}}"
+Color = 11772777
+Footer = "{{T \"Made with ❤️ by Aditya Diwakar\"}}"
+
+[[Fields]]
+Name = "{{T \"New Cases\"}}"
+Value = "{{T \"%s (%s vs. prior week)\" (number .Digest.Cases) (signed .Digest.Change)}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Peak Day\"}}"
+Value = "{{if .Digest.PeakDate}}{{date .Digest.PeakDate}} ({{number .Digest.Peak}}){{end}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"7 Day MA\"}}"
+Value = "{{decimal 1 .Digest.PreviousAverage}} → {{decimal 1 .Digest.Average}} {{.Digest.Trend}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Tests Administered\"}}"
+Value = "{{number .Digest.Tests}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Tested Positive\"}}"
+Value = "{{number .Digest.Positives}}"
+Inline = true
+
+[[Fields]]
+Name = "{{T \"Chart\"}}"
+Value = "{{.Digest.Chart}}"
+`,
+
+	"ops.failure": `
+Username = "GT Stamps Health Services"
+AvatarURL = "https://img.aditya.diwakar.io/stamps.png"
+Title = "{{.Ops.Source}}: {{.Ops.Check}} failing"
+Text = "{{.Ops.Error}}"
+Color = 15158332
+Footer = "Failing since {{.Ops.Since}} · sent once until it recovers"
+`,
+
+	"ops.recovery": `
+Username = "GT Stamps Health Services"
+AvatarURL = "https://img.aditya.diwakar.io/stamps.png"
+Title = "{{.Ops.Source}}: {{.Ops.Check}} recovered"
+Text = "Working again after failing since {{.Ops.Since}}."
+Color = 3066993
+`,
+}
@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Delivery is retried with exponential backoff, starting at FirstBackoff
+// and doubling up to MaxAttempts attempts in total. A destination asking
+// to wait longer than MaxWait is given up on instead.
+var (
+	MaxAttempts  = 5
+	FirstBackoff = time.Second
+	MaxWait      = time.Minute
+)
+
+// rateLimits holds, per webhook URL, when Discord's rate limit bucket
+// resets after it was exhausted.
+var rateLimits = struct {
+	sync.Mutex
+	reset map[string]time.Time
+}{reset: make(map[string]time.Time)}
+
+// observeRateLimit remembers when the bucket of rawURL resets if the last
+// response used it up.
+func observeRateLimit(rawURL string, h http.Header) {
+	if h.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	after, ok := parseSeconds(h.Get("X-RateLimit-Reset-After"))
+	if !ok {
+		return
+	}
+
+	rateLimits.Lock()
+	rateLimits.reset[rawURL] = time.Now().Add(after)
+	rateLimits.Unlock()
+}
+
+// waitRateLimit blocks until the bucket of rawURL has reset.
+func waitRateLimit(ctx context.Context, rawURL string) error {
+	rateLimits.Lock()
+	reset, ok := rateLimits.reset[rawURL]
+	delete(rateLimits.reset, rawURL)
+	rateLimits.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return sleep(ctx, time.Until(reset))
+}
+
+// retryAfter reads how long a rate limited destination asked to wait,
+// from Retry-After in seconds or as a date, or from Discord's
+// X-RateLimit-Reset-After.
+func retryAfter(h http.Header) time.Duration {
+	if v := h.Get("Retry-After"); v != "" {
+		if d, ok := parseSeconds(v); ok {
+			return d
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return time.Until(t)
+		}
+	}
+	if d, ok := parseSeconds(h.Get("X-RateLimit-Reset-After")); ok {
+		return d
+	}
+	return 0
+}
+
+// parseSeconds parses a possibly fractional number of seconds.
+func parseSeconds(v string) (time.Duration, bool) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return 0, false
+	}
+	return time.Duration(f * float64(time.Second)), true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryable reports whether err is worth another attempt: transport errors
+// and rate limited or failing destinations are, rejected messages are not.
+func retryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Temporary()
+	}
+	return true
+}
+
+// retry calls attempt until it succeeds, for as long as it may. It returns
+// the number of attempts made.
+func retry(ctx context.Context, attempt func() error) (int, error) {
+	backoff := FirstBackoff
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || n == MaxAttempts || !retryable(err) {
+			return n, err
+		}
+
+		wait := backoff
+		var se *StatusError
+		if errors.As(err, &se) && se.RetryAfter > wait {
+			wait = se.RetryAfter
+		}
+		if wait > MaxWait {
+			return n, err
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return n, err
+		}
+		backoff *= 2
+	}
+}
+
+// send delivers msg to d, retrying as long as it may succeed. It returns
+// the ID of the message where the destination can edit it, and the number
+// of attempts made.
+func send(ctx context.Context, d Destination, msg Message) (string, int, error) {
+	n, err := d.Notifier()
+	if err != nil {
+		return "", 0, err
+	}
+
+	var id string
+	attempts, err := retry(ctx, func() error {
+		if e, ok := n.(Editor); ok {
+			var err error
+			id, err = e.Post(ctx, msg)
+			return err
+		}
+		return n.Notify(ctx, msg)
+	})
+	return id, attempts, err
+}
+
+// logDelivery adds the outcome of delivering the template name to
+// subscription to the delivery log.
+func logDelivery(db *sql.DB, subscription int, name string, attempts int, sendErr error) {
+	var status sql.NullInt64
+	var message sql.NullString
+	if sendErr != nil {
+		message = sql.NullString{String: sendErr.Error(), Valid: true}
+		var se *StatusError
+		if errors.As(sendErr, &se) {
+			status = sql.NullInt64{Int64: int64(se.StatusCode), Valid: true}
+		}
+	}
+
+	_, err := db.Exec(`INSERT INTO deliveries (subscription_id, template, delivered, attempts, status_code, error)
+		VALUES ($1, $2, $3, $4, $5, $6)`, subscription, name, sendErr == nil, attempts, status, message)
+	if err != nil {
+		log.Printf("error: could not log delivery to subscription %d: %v\n", subscription, err)
+	}
+}
+
+// Send delivers msg, rendered from the template name, to d and logs the
+// delivery. It returns the ID of the message if d can edit it. A message
+// that could not be delivered is kept as a dead letter for Redeliver.
+func Send(ctx context.Context, db *sql.DB, d Destination, name string, msg Message) (string, error) {
+	id, attempts, err := send(ctx, d, msg)
+	logDelivery(db, d.ID, name, attempts, err)
+	if err == nil {
+		return id, nil
+	}
+
+	payload, jsonErr := json.Marshal(msg)
+	if jsonErr != nil {
+		return "", err
+	}
+	_, dbErr := db.Exec(`INSERT INTO dead_letters (subscription_id, type, url, template, message, error, attempts)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, d.ID, d.Type, d.URL, name, payload, err.Error(), attempts)
+	if dbErr != nil {
+		log.Printf("error: could not keep dead letter for subscription %d: %v\n", d.ID, dbErr)
+	}
+	return "", err
+}
+
+// Deliver renders the template name for each destination and sends it,
+// logging the destinations that fail.
+func Deliver(ctx context.Context, db *sql.DB, templates *Templates, list []Destination, name string, data Data) {
+	for _, d := range list {
+		msg, err := templates.Render(name, d, data)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		id, err := Send(ctx, db, d, name, msg)
+		if err != nil {
+			log.Printf("error: could not notify subscription %d: %v\n", d.ID, err)
+		} else if id != "" && revisable(name) {
+			if err := keepPost(db, d.ID, id, name, data); err != nil {
+				log.Printf("error: could not keep message of subscription %d: %v\n", d.ID, err)
+			}
+		}
+	}
+}
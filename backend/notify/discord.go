@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord posts to a Discord channel webhook, rendering the message as an
+// embed.
+type Discord struct {
+	URL string
+}
+
+func (d Discord) Notify(ctx context.Context, msg Message) error {
+	_, err := d.Post(ctx, msg)
+	return err
+}
+
+// Post executes the webhook and waits for Discord to create the message,
+// so as to return its ID.
+func (d Discord) Post(ctx context.Context, msg Message) (string, error) {
+	contentType, body, err := discordBody(DiscordParams(msg), msg.Files)
+	if err != nil {
+		return "", err
+	}
+
+	sep := "?"
+	if strings.Contains(d.URL, "?") {
+		sep = "&"
+	}
+	res, err := do(ctx, "POST", d.URL+sep+"wait=true", contentType, body)
+	if err != nil {
+		return "", err
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(res, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// discordEdit is the body of a webhook message edit. The name and avatar
+// of a message cannot be changed.
+type discordEdit struct {
+	Content     string                    `json:"content"`
+	Embeds      []*discordgo.MessageEmbed `json:"embeds"`
+	Attachments []discordAttachment       `json:"attachments"`
+}
+
+// discordAttachment lists an upload of an edit, by its index among the
+// files, as one to keep.
+type discordAttachment struct {
+	ID       int    `json:"id"`
+	Filename string `json:"filename"`
+}
+
+// Edit replaces the message id posted through the webhook with msg. The
+// files of the original message are replaced with those of msg.
+func (d Discord) Edit(ctx context.Context, id string, msg Message) error {
+	attachments := []discordAttachment{}
+	for i, f := range msg.Files {
+		attachments = append(attachments, discordAttachment{ID: i, Filename: f.Name})
+	}
+	if strings.HasPrefix(msg.Image, "attachment://") && len(msg.Files) == 0 {
+		msg.Image = ""
+	}
+	params := DiscordParams(msg)
+	contentType, body, err := discordBody(discordEdit{
+		Content:     params.Content,
+		Embeds:      params.Embeds,
+		Attachments: attachments,
+	}, msg.Files)
+	if err != nil {
+		return err
+	}
+
+	rawURL := d.URL + "/messages/" + url.PathEscape(id)
+	if i := strings.Index(d.URL, "?"); i >= 0 {
+		rawURL = d.URL[:i] + "/messages/" + url.PathEscape(id) + d.URL[i:]
+	}
+	_, err = do(ctx, "PATCH", rawURL, contentType, body)
+	return err
+}
+
+// discordBody encodes payload as JSON, or as multipart form data next to
+// the files if there are any.
+func discordBody(payload interface{}, files []File) (string, []byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(files) == 0 {
+		return "application/json", data, nil
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := w.WriteField("payload_json", string(data)); err != nil {
+		return "", nil, err
+	}
+
+	for i, f := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[%d]"; filename="%s"`, i, f.Name))
+		h.Set("Content-Type", f.ContentType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := part.Write(f.Data); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", nil, err
+	}
+
+	return w.FormDataContentType(), body.Bytes(), nil
+}
+
+// DiscordParams renders msg as a Discord webhook execution.
+func DiscordParams(msg Message) *discordgo.WebhookParams {
+	params := &discordgo.WebhookParams{
+		Username:  msg.Username,
+		AvatarURL: msg.AvatarURL,
+		Content:   msg.Text,
+	}
+	if msg.Title == "" && len(msg.Fields) == 0 {
+		return params
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: msg.Title,
+		URL:   msg.URL,
+		Color: msg.Color,
+	}
+	if msg.Image != "" {
+		embed.Image = &discordgo.MessageEmbedImage{URL: msg.Image}
+	}
+	if msg.Footer != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: msg.Footer}
+	}
+	for _, f := range msg.Fields {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   f.Name,
+			Value:  f.Value,
+			Inline: f.Inline,
+		})
+	}
+
+	params.Embeds = []*discordgo.MessageEmbed{embed}
+	return params
+}
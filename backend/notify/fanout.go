@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/events"
+	"github.com/go-redis/redis/v8"
+)
+
+// Alert types of a webhook subscription, as defined by the backend.
+const (
+	AlertDaily    = "daily"
+	AlertRevision = "revision"
+	AlertWeekly   = "weekly"
+)
+
+// Broadcast renders the template name for every subscriber of alertType
+// to dataset and delivers it in the format of the destination. With
+// combined enabled, daily posts to subscribers of both datasets wait for
+// the other scraper.
+func Broadcast(ctx context.Context, db *sql.DB, templates *Templates, combined Combined, dataset, alertType, name string, data Data) {
+	list, err := Subscribers(db, dataset, alertType)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if alertType == AlertDaily && combined.Enabled {
+		combined.Deliver(ctx, db, templates, list, name, data)
+		return
+	}
+	Deliver(ctx, db, templates, list, name, data)
+}
+
+// FireRules evaluates rules against days of dataset, oldest first and
+// ending with date, and alerts each rule that fires the first time it does
+// so for date.
+func FireRules(ctx context.Context, db *sql.DB, rdb *redis.Client, templates *Templates, rules []Rule, dataset, date string, days []Day) {
+	if len(days) == 0 {
+		return
+	}
+	latest := days[len(days)-1]
+
+	var previous map[string]int
+	if len(days) > 1 {
+		previous = days[len(days)-2].Values
+	}
+
+	for _, rule := range rules {
+		description, fired := rule.Evaluate(days)
+		if !fired {
+			continue
+		}
+
+		seen, err := events.Alerted(db, dataset, rule.Name, date)
+		if err != nil {
+			log.Println(err)
+			continue
+		} else if seen {
+			continue
+		}
+
+		_, err = events.Publish(ctx, db, rdb, events.KindAlert, date, events.Alert{
+			Dataset:     dataset,
+			Rule:        rule.Name,
+			Severity:    rule.Severity,
+			Description: description.String(),
+			Values:      latest.Values,
+		})
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		list, err := rule.Destinations(db)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		data := NewData(dataset, date, latest.Values, previous)
+		data.Alert = rule.Alert(description)
+		Deliver(ctx, db, templates, list, dataset+".alert", data)
+	}
+}
+
+// ReviseDay revises the figures stored for date in dataset if GT has
+// corrected them, then edits the daily posts of date and delivers the
+// revision to its subscribers. chart, if not nil, draws the chart of the
+// posts again.
+func ReviseDay(ctx context.Context, db *sql.DB, rdb *redis.Client, templates *Templates, dataset, date string, after map[string]int, chart func() *File) error {
+	before, err := events.Revise(ctx, db, rdb, dataset, date, after)
+	if err != nil || before == nil {
+		return err
+	}
+
+	var file *File
+	if chart != nil {
+		file = chart()
+	}
+	Revise(ctx, db, templates, dataset, date, after, file)
+	Broadcast(ctx, db, templates, Combined{}, dataset, AlertRevision, dataset+".revision", NewData(dataset, date, after, before))
+	return nil
+}
@@ -0,0 +1,99 @@
+package notify
+
+import "context"
+
+// GoogleChat posts to a Google Chat space webhook as a card.
+type GoogleChat struct {
+	URL string
+}
+
+type chatWidget struct {
+	KeyValue      *chatKeyValue `json:"keyValue,omitempty"`
+	TextParagraph *chatText     `json:"textParagraph,omitempty"`
+	Image         *chatImage    `json:"image,omitempty"`
+	Buttons       []chatButton  `json:"buttons,omitempty"`
+}
+
+type chatKeyValue struct {
+	TopLabel string `json:"topLabel"`
+	Content  string `json:"content"`
+}
+
+type chatText struct {
+	Text string `json:"text"`
+}
+
+type chatImage struct {
+	ImageURL string `json:"imageUrl"`
+}
+
+type chatButton struct {
+	TextButton chatTextButton `json:"textButton"`
+}
+
+type chatTextButton struct {
+	Text    string      `json:"text"`
+	OnClick chatOnClick `json:"onClick"`
+}
+
+type chatOnClick struct {
+	OpenLink struct {
+		URL string `json:"url"`
+	} `json:"openLink"`
+}
+
+type chatSection struct {
+	Widgets []chatWidget `json:"widgets"`
+}
+
+type chatHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+	ImageURL string `json:"imageUrl,omitempty"`
+}
+
+type chatCard struct {
+	Header   *chatHeader   `json:"header,omitempty"`
+	Sections []chatSection `json:"sections"`
+}
+
+type chatPayload struct {
+	Text  string     `json:"text,omitempty"`
+	Cards []chatCard `json:"cards,omitempty"`
+}
+
+func (g GoogleChat) Notify(ctx context.Context, msg Message) error {
+	payload := chatPayload{Text: msg.Text}
+
+	if msg.Title != "" || len(msg.Fields) > 0 {
+		card := chatCard{Header: &chatHeader{Title: msg.Title, Subtitle: msg.Username, ImageURL: msg.AvatarURL}}
+
+		if len(msg.Fields) > 0 {
+			section := chatSection{}
+			for _, f := range msg.Fields {
+				section.Widgets = append(section.Widgets, chatWidget{KeyValue: &chatKeyValue{f.Name, f.Value}})
+			}
+			if webImage(msg.Image) {
+				section.Widgets = append(section.Widgets, chatWidget{Image: &chatImage{msg.Image}})
+			}
+			card.Sections = append(card.Sections, section)
+		}
+
+		footer := chatSection{}
+		if msg.Footer != "" {
+			footer.Widgets = append(footer.Widgets, chatWidget{TextParagraph: &chatText{msg.Footer}})
+		}
+		if msg.URL != "" {
+			button := chatButton{TextButton: chatTextButton{Text: "VIEW SOURCE"}}
+			button.TextButton.OnClick.OpenLink.URL = msg.URL
+			footer.Widgets = append(footer.Widgets, chatWidget{Buttons: []chatButton{button}})
+		}
+		if len(footer.Widgets) > 0 {
+			card.Sections = append(card.Sections, footer)
+		}
+
+		payload.Cards = []chatCard{card}
+	}
+
+	return postJSON(ctx, g.URL, payload)
+}
@@ -0,0 +1,18 @@
+package notify
+
+import "context"
+
+// JSON posts the Message itself, for destinations that do their own
+// rendering. Uploads are left out, and so are images referring to them,
+// which the destination could not resolve.
+type JSON struct {
+	URL string
+}
+
+func (j JSON) Notify(ctx context.Context, msg Message) error {
+	msg.Files = nil
+	if !webImage(msg.Image) {
+		msg.Image = ""
+	}
+	return postJSON(ctx, j.URL, msg)
+}
@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// Locales are the languages a subscription can receive its posts in.
+var Locales = []string{"en", "es", "zh"}
+
+// DefaultLocale is the locale of subscriptions that do not set one.
+const DefaultLocale = "en"
+
+var localeTags = map[string]language.Tag{
+	"en": language.English,
+	"es": language.Spanish,
+	"zh": language.SimplifiedChinese,
+}
+
+// translations of the labels of the default templates, keyed by their
+// English text. Labels without a translation are posted in English.
+var translations = map[string]map[string]string{
+	"es": {
+		"[%s] GT COVID-19 Update":                   "[%s] Actualización de COVID-19 en GT",
+		"[%s] GT COVID-19 Daily Update":             "[%s] Actualización diaria de COVID-19 en GT",
+		"[%s] GT COVID-19 Alert: %s":                "[%s] Alerta de COVID-19 en GT: %s",
+		"[%s] Surveillance Testing Program Results": "[%s] Resultados del programa de pruebas de vigilancia",
+		"[%s] Surveillance Testing Alert: %s":       "[%s] Alerta de las pruebas de vigilancia: %s",
+		"[%s – %s] GT COVID-19 Weekly Digest":       "[%s – %s] Resumen semanal de COVID-19 en GT",
+		"(Revised)":                                 "(Corregido)",
+		"Made with ❤️ by Aditya Diwakar":            "Hecho con ❤️ por Aditya Diwakar",
+		"Severity: %s":                              "Gravedad: %s",
+		"info":                                      "información",
+		"warning":                                   "advertencia",
+		"critical":                                  "crítica",
+		"Reported Today":                            "Reportados hoy",
+		"Total":                                     "Total",
+		"7/30 Day MA":                               "Media móvil de 7/30 días",
+		"7 Day MA":                                  "Media móvil de 7 días",
+		"Last 30 Days":                              "Últimos 30 días",
+		"Tested Positive (All Time)":                "Positivos (total)",
+		"Tests Administered":                        "Pruebas realizadas",
+		"Tested Positive":                           "Positivos",
+		"%s cases reported":                         "%s casos reportados",
+		", and %s of %s surveillance tests positive": ", y %s de %s pruebas de vigilancia positivas",
+		".":                      ".",
+		"New Cases":              "Casos nuevos",
+		"%s (%s vs. prior week)": "%s (%s frente a la semana anterior)",
+		"Peak Day":               "Día con más casos",
+		"Chart":                  "Gráfico",
+		"%d cases reported, more than %g× the %d-day average of %.1f": "%d casos reportados, más de %g× la media de %d días de %.1f",
+		"%.2f%% of the latest %d tests were positive, above %g%%":     "%.2f%% de las últimas %d pruebas fueron positivas, por encima del %g%%",
+		"Reported cases rose %d days in a row, to %d":                 "Los casos reportados subieron %d días seguidos, hasta %d",
+		"%d cases reported, the most on any day so far":               "%d casos reportados, la mayor cifra de un día hasta ahora",
+	},
+	"zh": {
+		"[%s] GT COVID-19 Update":                   "[%s] 佐治亚理工 COVID-19 更新",
+		"[%s] GT COVID-19 Daily Update":             "[%s] 佐治亚理工 COVID-19 每日更新",
+		"[%s] GT COVID-19 Alert: %s":                "[%s] 佐治亚理工 COVID-19 警报：%s",
+		"[%s] Surveillance Testing Program Results": "[%s] 监测检测项目结果",
+		"[%s] Surveillance Testing Alert: %s":       "[%s] 监测检测警报：%s",
+		"[%s – %s] GT COVID-19 Weekly Digest":       "[%s – %s] 佐治亚理工 COVID-19 每周摘要",
+		"(Revised)":                                 "（已更正）",
+		"Made with ❤️ by Aditya Diwakar":            "由 Aditya Diwakar 用 ❤️ 制作",
+		"Severity: %s":                              "严重程度：%s",
+		"info":                                      "信息",
+		"warning":                                   "警告",
+		"critical":                                  "严重",
+		"Reported Today":                            "今日报告",
+		"Total":                                     "累计",
+		"7/30 Day MA":                               "7/30 日移动平均",
+		"7 Day MA":                                  "7 日移动平均",
+		"Last 30 Days":                              "最近 30 天",
+		"Tested Positive (All Time)":                "累计阳性",
+		"Tests Administered":                        "检测次数",
+		"Tested Positive":                           "阳性",
+		"%s cases reported":                         "报告 %s 例病例",
+		", and %s of %s surveillance tests positive": "，%[2]s 次监测检测中 %[1]s 次呈阳性",
+		".":                      "。",
+		"New Cases":              "新增病例",
+		"%s (%s vs. prior week)": "%s（较上周 %s）",
+		"Peak Day":               "最高日",
+		"Chart":                  "图表",
+		"%d cases reported, more than %g× the %d-day average of %.1f": "报告 %[1]d 例病例，超过 %[3]d 日平均值 %.1[4]f 的 %[2]g 倍",
+		"%.2f%% of the latest %d tests were positive, above %g%%":     "最近 %[2]d 次检测中 %.2[1]f%% 呈阳性，高于 %[3]g%%",
+		"Reported cases rose %d days in a row, to %d":                 "报告病例连续 %d 天上升，达到 %d 例",
+		"%d cases reported, the most on any day so far":               "报告 %d 例病例，为迄今单日最多",
+	},
+}
+
+// monthNames are used to write dates in locales other than English.
+var monthNames = map[string][]string{
+	"es": {"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio",
+		"agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+}
+
+var printers = make(map[string]*message.Printer)
+
+func init() {
+	b := catalog.NewBuilder()
+	for locale, messages := range translations {
+		for key, msg := range messages {
+			if err := b.SetString(localeTags[locale], key, msg); err != nil {
+				panic(err)
+			}
+		}
+	}
+	for locale, tag := range localeTags {
+		printers[locale] = message.NewPrinter(tag, message.Catalog(b))
+	}
+}
+
+// CheckLocale rejects locales posts cannot be written in.
+func CheckLocale(locale string) error {
+	if _, ok := localeTags[locale]; !ok {
+		return fmt.Errorf("unknown locale %q, must be one of en, es, zh", locale)
+	}
+	return nil
+}
+
+// localeFuncs are the template functions writing in locale.
+func localeFuncs(locale string) template.FuncMap {
+	p := printers[locale]
+	return template.FuncMap{
+		// number formats n with thousands separators
+		"number": func(n int) string { return p.Sprintf("%d", n) },
+		// signed is number with a + for positive numbers
+		"signed": func(n int) string {
+			if n > 0 {
+				return p.Sprintf("+%d", n)
+			}
+			return p.Sprintf("%d", n)
+		},
+		// decimal formats f with the given number of decimals
+		"decimal": func(decimals int, f float64) string {
+			return p.Sprintf(fmt.Sprintf("%%.%df", decimals), f)
+		},
+		// T translates an English label, formatted with args as by printf
+		"T": func(label string, args ...interface{}) string { return p.Sprintf(label, args...) },
+		// date writes a date as published by GT in the locale
+		"date": func(date string) string { return localDate(locale, date) },
+		// sparkline draws numbers as a line of block characters
+		"sparkline": sparkline,
+	}
+}
+
+// localDate rewrites date, as GT publishes it or as YYYY-MM-DD, for locale.
+// Dates it cannot read are left as they are.
+func localDate(locale, date string) string {
+	t, err := gtdate.Parse(date)
+	if err != nil {
+		return date
+	}
+
+	switch locale {
+	case "en":
+		return t.Format(gtdate.Layouts[0])
+	case "es":
+		return fmt.Sprintf("%d de %s de %d", t.Day(), monthNames["es"][t.Month()-1], t.Year())
+	case "zh":
+		return fmt.Sprintf("%d年%d月%d日", t.Year(), t.Month(), t.Day())
+	}
+	return date
+}
@@ -0,0 +1,115 @@
+package notify
+
+import "testing"
+
+func TestLocalDate(t *testing.T) {
+	tests := []struct {
+		locale string
+		date   string
+		want   string
+	}{
+		{"en", "September 21, 2020", "September 21, 2020"},
+		{"en", "Sep 21, 2020", "September 21, 2020"},
+		{"en", "2020-09-21", "September 21, 2020"},
+		{"es", "September 21, 2020", "21 de septiembre de 2020"},
+		{"es", "9/1/2020", "1 de septiembre de 2020"},
+		{"es", " 2021-01-03 ", "3 de enero de 2021"},
+		{"zh", "September 21, 2020", "2020年9月21日"},
+		{"zh", "Monday, September 21, 2020", "2020年9月21日"},
+		{"es", "sometime", "sometime"},
+		{"zh", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := localDate(tt.locale, tt.date); got != tt.want {
+			t.Errorf("localDate(%q, %q) = %q, want %q", tt.locale, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestRenderLocales(t *testing.T) {
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	daily := NewData("cases", "September 21, 2020", map[string]int{"reported": 1234, "total": 56789}, map[string]int{"reported": 1000, "total": 55555})
+	daily.Averages["seven"], daily.Averages["thirty"] = 1012.5, 987.25
+
+	description, _ := Rule{Name: "high", Kind: RuleAllTimeHigh, Severity: "warning"}.Evaluate([]Day{
+		{Values: map[string]int{"reported": 1000}},
+		{Values: map[string]int{"reported": 1234}},
+	})
+	alert := daily
+	alert.Alert = Rule{Name: "high", Severity: "warning"}.Alert(description)
+
+	digest := Data{Dataset: "digest", Link: Links["cases"], Digest: Digest{
+		Start: "2020-09-14", End: "2020-09-20", Cases: 1500, Change: -25, PeakDate: "2020-09-16", Peak: 400,
+	}}
+	digest.Date = digest.Digest.Start
+
+	tests := []struct {
+		name      string
+		data      Data
+		locale    string
+		wantTitle string
+		wantText  string
+		field     int // index of the field checked
+		wantName  string
+		wantValue string
+	}{
+		{"cases.daily", daily, "en", "[September 21, 2020] GT COVID-19 Update", "", 2, "7/30 Day MA", "1,012.5/987.2"},
+		{"cases.daily", daily, "es", "[21 de septiembre de 2020] Actualización de COVID-19 en GT", "", 2, "Media móvil de 7/30 días", "1.012,5/987,2"},
+		{"cases.daily", daily, "zh", "[2020年9月21日] 佐治亚理工 COVID-19 更新", "", 0, "今日报告", "1,234"},
+		{"cases.alert", alert, "en", "[September 21, 2020] GT COVID-19 Alert: high", "1,234 cases reported, the most on any day so far", 0, "Reported Today", "1,234"},
+		{"cases.alert", alert, "es", "[21 de septiembre de 2020] Alerta de COVID-19 en GT: high", "1.234 casos reportados, la mayor cifra de un día hasta ahora", 0, "Reportados hoy", "1.234"},
+		{"cases.alert", alert, "zh", "[2020年9月21日] 佐治亚理工 COVID-19 警报：high", "报告 1,234 例病例，为迄今单日最多", 0, "今日报告", "1,234"},
+		{"digest", digest, "en", "[September 14, 2020 – September 20, 2020] GT COVID-19 Weekly Digest", "", 1, "Peak Day", "September 16, 2020 (400)"},
+		{"digest", digest, "es", "[14 de septiembre de 2020 – 20 de septiembre de 2020] Resumen semanal de COVID-19 en GT", "", 0, "Casos nuevos", "1.500 (-25 frente a la semana anterior)"},
+		{"digest", digest, "zh", "[2020年9月14日 – 2020年9月20日] 佐治亚理工 COVID-19 每周摘要", "", 1, "最高日", "2020年9月16日 (400)"},
+	}
+
+	for _, tt := range tests {
+		msg, err := templates.Render(tt.name, Destination{Type: TypeSlack, Locale: tt.locale}, tt.data)
+		if err != nil {
+			t.Errorf("%s in %s: %v", tt.name, tt.locale, err)
+			continue
+		}
+		if msg.Title != tt.wantTitle {
+			t.Errorf("%s in %s: title %q, want %q", tt.name, tt.locale, msg.Title, tt.wantTitle)
+		}
+		if msg.Text != tt.wantText {
+			t.Errorf("%s in %s: text %q, want %q", tt.name, tt.locale, msg.Text, tt.wantText)
+		}
+		if tt.field >= len(msg.Fields) {
+			t.Errorf("%s in %s: %d fields, want field %d", tt.name, tt.locale, len(msg.Fields), tt.field)
+			continue
+		}
+		if f := msg.Fields[tt.field]; f.Name != tt.wantName || f.Value != tt.wantValue {
+			t.Errorf("%s in %s: field %q: %q, want %q: %q", tt.name, tt.locale, f.Name, f.Value, tt.wantName, tt.wantValue)
+		}
+	}
+}
+
+func TestDescriptionIn(t *testing.T) {
+	description, fired := Rule{Kind: RuleAllTimeHigh}.Evaluate(reported(1000, 1500))
+	if !fired {
+		t.Fatal("rule did not fire")
+	}
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"en", "1,500 cases reported, the most on any day so far"},
+		{"es", "1.500 casos reportados, la mayor cifra de un día hasta ahora"},
+		{"zh", "报告 1,500 例病例，为迄今单日最多"},
+		{"", "1,500 cases reported, the most on any day so far"},
+	}
+
+	for _, tt := range tests {
+		if got := description.In(tt.locale); got != tt.want {
+			t.Errorf("In(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
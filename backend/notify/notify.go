@@ -0,0 +1,286 @@
+// Package notify delivers the scrapers' notifications to chat services. A
+// Message describes what to say; each Notifier renders it in the format of
+// its destination, so one event can fan out to Discord, Slack, Microsoft
+// Teams, Google Chat and plain JSON webhooks alike.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Destination types, as stored with each webhook subscription.
+const (
+	TypeDiscord    = "discord"
+	TypeSlack      = "slack"
+	TypeTeams      = "teams"
+	TypeGoogleChat = "googlechat"
+	TypeJSON       = "json"
+)
+
+// Types lists every destination type.
+var Types = []string{TypeDiscord, TypeSlack, TypeTeams, TypeGoogleChat, TypeJSON}
+
+// Message is a notification independent of where it is delivered. A
+// message with only Text is sent as plain text.
+type Message struct {
+	Username  string  `json:"username,omitempty"`
+	AvatarURL string  `json:"avatar_url,omitempty"`
+	Text      string  `json:"text,omitempty"`
+	Title     string  `json:"title,omitempty"`
+	URL       string  `json:"url,omitempty"`
+	Color     int     `json:"color,omitempty"`
+	Fields    []Field `json:"fields,omitempty"`
+	Image     string  `json:"image,omitempty"`
+	Footer    string  `json:"footer,omitempty"`
+	Files     []File  `json:"files,omitempty"`
+}
+
+type Field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// File is uploaded along with a message by destinations that take uploads.
+// The message refers to it as attachment://NAME, for instance in Image.
+type File struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// Notifier delivers messages to one destination.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Editor is a Notifier whose messages can be edited once sent. Post
+// returns the ID Edit takes.
+type Editor interface {
+	Notifier
+	Post(ctx context.Context, msg Message) (string, error)
+	Edit(ctx context.Context, id string, msg Message) error
+}
+
+// New returns the Notifier for a destination of the given type. The URL is
+// checked with CheckURL.
+func New(kind, rawURL string) (Notifier, error) {
+	if err := CheckURL(kind, rawURL); err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case TypeDiscord:
+		return Discord{URL: rawURL}, nil
+	case TypeSlack:
+		return Slack{URL: rawURL}, nil
+	case TypeTeams:
+		return Teams{URL: rawURL}, nil
+	case TypeGoogleChat:
+		return GoogleChat{URL: rawURL}, nil
+	default:
+		return JSON{URL: rawURL}, nil
+	}
+}
+
+// webhookHosts are the hosts and path prefixes of each service's incoming
+// webhooks. JSON destinations may use any public https host.
+var webhookHosts = map[string]map[string]string{
+	TypeDiscord: {
+		"discord.com":        "/api/webhooks/",
+		"discordapp.com":     "/api/webhooks/",
+		"ptb.discord.com":    "/api/webhooks/",
+		"canary.discord.com": "/api/webhooks/",
+	},
+	TypeSlack: {
+		"hooks.slack.com": "/services/",
+	},
+	TypeTeams: {
+		"outlook.office.com":    "/webhook/",
+		"outlook.office365.com": "/webhook/",
+	},
+	TypeGoogleChat: {
+		"chat.googleapis.com": "/v1/spaces/",
+	},
+}
+
+// CheckURL accepts https webhook URLs of the service named by kind.
+// Destinations are registered through the public API, so the URL must not
+// point the scrapers at internal hosts: every type requires https, the
+// services are limited to their own hosts, and the dialer refuses
+// non-public addresses at connection time.
+func CheckURL(kind, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return errors.New("url must be an https URL")
+	}
+	host := strings.ToLower(u.Hostname())
+
+	switch kind {
+	case TypeJSON:
+		if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+			return errors.New("url must not point to a private address")
+		}
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+			return errors.New("url must not point to a private address")
+		}
+		return nil
+	case TypeTeams:
+		// connectors are also served from each tenant's own subdomain
+		if strings.HasSuffix(host, ".webhook.office.com") && strings.HasPrefix(u.Path, "/webhookb2/") {
+			return nil
+		}
+	}
+
+	hosts, ok := webhookHosts[kind]
+	if !ok {
+		return fmt.Errorf("type must be one of %s", strings.Join(Types, ", "))
+	}
+	if prefix, ok := hosts[host]; !ok || !strings.HasPrefix(u.Path, prefix) {
+		return fmt.Errorf("url must be a %s webhook URL", kind)
+	}
+	return nil
+}
+
+var privateNets = func() []*net.IPNet {
+	list := []*net.IPNet{}
+	for _, cidr := range []string{
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8",
+		"169.254.0.0/16", "172.16.0.0/12", "192.0.0.0/24", "192.168.0.0/16",
+		"198.18.0.0/15", "::1/128", "fc00::/7", "fe80::/10",
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		list = append(list, n)
+	}
+	return list
+}()
+
+func publicIP(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// refusePrivate stops connections to non-public addresses, including
+// hosts that resolve to one and redirects to one.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("refusing to connect to %s", host)
+	}
+	return nil
+}
+
+// Client is the HTTP client used for every delivery.
+var Client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: refusePrivate,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// StatusError is returned when a destination answers with a non-2xx
+// status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration // how long the destination asked to wait, if it did
+}
+
+func (e *StatusError) Error() string {
+	return "webhook answered " + e.Status
+}
+
+// Temporary reports whether the request may succeed if sent again: the
+// destination was rate limited or failed on its side.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// postJSON posts payload to rawURL and checks that it was accepted.
+func postJSON(ctx context.Context, rawURL string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return post(ctx, rawURL, "application/json", body)
+}
+
+// post sends body to rawURL, waiting for its rate limit to reset first,
+// and checks that it was accepted.
+func post(ctx context.Context, rawURL, contentType string, body []byte) error {
+	_, err := do(ctx, "POST", rawURL, contentType, body)
+	return err
+}
+
+// do sends a request to a destination and returns the body of its answer.
+func do(ctx context.Context, method, rawURL, contentType string, body []byte) ([]byte, error) {
+	// the rate limit is that of the webhook, whatever the query
+	limited := rawURL
+	if i := strings.Index(rawURL, "?"); i >= 0 {
+		limited = rawURL[:i]
+	}
+	if err := waitRateLimit(ctx, limited); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+
+	res, err := Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	observeRateLimit(limited, res.Header)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &StatusError{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			RetryAfter: retryAfter(res.Header),
+		}
+	}
+	return ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+}
+
+// webImage reports whether img is an image on the web rather than an
+// uploaded file, which only Discord takes.
+func webImage(img string) bool {
+	return strings.HasPrefix(img, "https://") || strings.HasPrefix(img, "http://")
+}
+
+// hexColor formats a Discord embed color for services that take CSS colors.
+func hexColor(color int) string {
+	return fmt.Sprintf("#%06x", color&0xffffff)
+}
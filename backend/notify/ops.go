@@ -0,0 +1,205 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Checks of a scraper, each alerted once when it fails and once when it
+// works again.
+const (
+	CheckFetch    = "fetch"    // GT's page
+	CheckParse    = "parse"    // the figures on it
+	CheckAPI      = "api"      // the backend's API
+	CheckDatabase = "database" // postgres
+	CheckRedis    = "redis"
+	CheckStale    = "stale" // GT publishing new figures
+)
+
+// Ops configures the operational alerts of a scraper: its failures, and
+// GT going quiet. They go to webhooks of their own rather than to
+// subscriptions, so that they get out when the database is down.
+type Ops struct {
+	Webhooks  []Destination
+	StaleDays int        // alert when GT has published nothing new for this many days, 0 never does
+	Semesters []Semester // the staleness alert is only sent during these, or always if there are none
+
+	semesters [][2]time.Time
+}
+
+// Semester is a term during which GT is expected to publish, from Start to
+// End inclusive, both formatted as YYYY-MM-DD.
+type Semester struct {
+	Start string
+	End   string
+}
+
+// OpsEvent describes to the ops templates a check of a scraper that
+// failed, or that works again.
+type OpsEvent struct {
+	Source string // the scraper
+	Check  string // what failed, such as fetch, parse or database
+	Error  string
+	Since  string // when the check started failing
+}
+
+// Check validates o and fills in its defaults.
+func (o *Ops) Check() error {
+	for i := range o.Webhooks {
+		w := &o.Webhooks[i]
+		if w.Type == "" {
+			w.Type = TypeDiscord
+		}
+		if err := CheckURL(w.Type, w.URL); err != nil {
+			return fmt.Errorf("webhook %d: %v", i+1, err)
+		}
+		if w.Locale == "" {
+			w.Locale = DefaultLocale
+		}
+		if err := CheckLocale(w.Locale); err != nil {
+			return fmt.Errorf("webhook %d: %v", i+1, err)
+		}
+	}
+
+	if o.StaleDays < 0 {
+		return fmt.Errorf("StaleDays must not be negative")
+	}
+
+	o.semesters = nil
+	for _, s := range o.Semesters {
+		start, err := time.Parse("2006-01-02", s.Start)
+		if err != nil {
+			return fmt.Errorf("semester start %q must be formatted as YYYY-MM-DD", s.Start)
+		}
+		end, err := time.Parse("2006-01-02", s.End)
+		if err != nil {
+			return fmt.Errorf("semester end %q must be formatted as YYYY-MM-DD", s.End)
+		}
+		o.semesters = append(o.semesters, [2]time.Time{start, end.AddDate(0, 0, 1)})
+	}
+	return nil
+}
+
+// Stale reports whether having heard from GT last at seen is worth an
+// alert at now.
+func (o Ops) Stale(seen, now time.Time) bool {
+	if o.StaleDays == 0 || now.Sub(seen) < time.Duration(o.StaleDays)*24*time.Hour {
+		return false
+	}
+	if len(o.semesters) == 0 {
+		return true
+	}
+	for _, s := range o.semesters {
+		if !now.Before(s[0]) && now.Before(s[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Send renders the ops template name for each ops webhook and sends it.
+// Nothing is logged to the database, which may be what failed.
+func (o Ops) Send(ctx context.Context, templates *Templates, name string, event OpsEvent) {
+	data := Data{Dataset: "ops", Ops: event}
+
+	for _, d := range o.Webhooks {
+		msg, err := templates.Render(name, d, data)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if _, _, err := send(ctx, d, msg); err != nil {
+			log.Printf("error: could not send ops alert to %s webhook %d: %v\n", d.Type, d.ID, err)
+		}
+	}
+}
+
+func opsKey(source, check string) string {
+	return "gt.ops." + source + "." + check
+}
+
+// lastSeenKey holds when GT last published a new day to source, as a Unix
+// time.
+func lastSeenKey(source string) string {
+	return "gt.ops." + source + ".lastseen"
+}
+
+// Fail alerts the ops webhooks that check of the scraper source failed,
+// unless they already were and it has not recovered since. Redis remembers
+// what was sent; while it is down, every failure is sent.
+func (o Ops) Fail(ctx context.Context, rdb *redis.Client, templates *Templates, source, check string, err error) {
+	log.Printf("error: %s: %v\n", check, err)
+
+	since := time.Now().Format("Jan 2 15:04 MST")
+	first, rerr := rdb.SetNX(ctx, opsKey(source, check), since, 0).Result()
+	if rerr == nil && !first {
+		return
+	}
+
+	o.Send(ctx, templates, "ops.failure", OpsEvent{
+		Source: source,
+		Check:  check,
+		Error:  err.Error(),
+		Since:  since,
+	})
+}
+
+// Fatal is Fail for errors the scraper cannot go on after.
+func (o Ops) Fatal(ctx context.Context, rdb *redis.Client, templates *Templates, source, check string, err error) {
+	o.Fail(ctx, rdb, templates, source, check, err)
+	os.Exit(1)
+}
+
+// Recovered tells the ops webhooks that check of the scraper source works
+// again if they were told it failed.
+func (o Ops) Recovered(ctx context.Context, rdb *redis.Client, templates *Templates, source, check string) {
+	since, err := rdb.Get(ctx, opsKey(source, check)).Result()
+	if err != nil {
+		return
+	}
+	// another run may have got there first
+	if n, err := rdb.Del(ctx, opsKey(source, check)).Result(); err != nil || n == 0 {
+		return
+	}
+
+	o.Send(ctx, templates, "ops.recovery", OpsEvent{
+		Source: source,
+		Check:  check,
+		Since:  since,
+	})
+}
+
+// MarkFresh records that GT just published a new day to the scraper source.
+func (o Ops) MarkFresh(ctx context.Context, rdb *redis.Client, templates *Templates, source string) {
+	rdb.Set(ctx, lastSeenKey(source), time.Now().Unix(), 0)
+	o.Recovered(ctx, rdb, templates, source, CheckStale)
+}
+
+// CheckFreshness alerts when GT has not published a new day to the scraper
+// source for as long as o allows.
+func (o Ops) CheckFreshness(ctx context.Context, rdb *redis.Client, templates *Templates, source string) {
+	value, err := rdb.Get(ctx, lastSeenKey(source)).Result()
+	if err != nil {
+		// start counting from the first run that knows to
+		rdb.SetNX(ctx, lastSeenKey(source), time.Now().Unix(), 0)
+		return
+	}
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return
+	}
+
+	seen := time.Unix(unix, 0)
+	if o.Stale(seen, time.Now()) {
+		o.Fail(ctx, rdb, templates, source, CheckStale, fmt.Errorf("GT has published nothing new since %s", seen.Format("Jan 2 15:04 MST")))
+	} else {
+		o.Recovered(ctx, rdb, templates, source, CheckStale)
+	}
+}
@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// revisable reports whether posts of the template name are edited when GT
+// corrects their figures.
+func revisable(name string) bool {
+	return name == CombinedTemplate || strings.HasSuffix(name, ".daily")
+}
+
+// keepPost remembers the message a daily post was sent as, with the data
+// it was rendered from, so that Revise can edit it. A combined post is kept
+// under the day of each dataset.
+func keepPost(db *sql.DB, subscription int, id, name string, data Data) error {
+	// the chart is not uploaded again when editing
+	data.Chart = nil
+	days := []*Data{&data}
+	if data.Cases != nil && data.Surveys != nil {
+		days = []*Data{data.Cases, data.Surveys}
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	for _, day := range days {
+		_, err := db.Exec(`INSERT INTO sent_messages (subscription_id, dataset, date, template, message_id, data)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (subscription_id, dataset, date) DO UPDATE
+			SET template = EXCLUDED.template, message_id = EXCLUDED.message_id, data = EXCLUDED.data`,
+			subscription, day.Dataset, day.Date, name, id, payload)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revise replaces the figures of d with values, keeping what they are
+// compared with, and recomputes what is derived from them.
+func (d *Data) revise(values map[string]int) {
+	d.Values = values
+	for key := range d.Deltas {
+		d.Deltas[key] = values[key] - d.Previous[key]
+	}
+	d.Positivity = 0
+	if tests := d.Deltas["administered"]; tests > 0 {
+		d.Positivity = 100 * float64(d.Deltas["positive"]) / float64(tests)
+	}
+	if n := len(d.Series); n > 0 {
+		d.Series[n-1] = values["reported"]
+	}
+	for key, window := range map[string]int{"seven": 7, "thirty": 30} {
+		if _, ok := d.Averages[key]; ok && len(d.Series) >= window {
+			sum := 0
+			for _, v := range d.Series[len(d.Series)-window:] {
+				sum += v
+			}
+			d.Averages[key] = float64(sum) / float64(window)
+		}
+	}
+	d.Revised = true
+}
+
+// Revise edits the daily posts of dataset for date in place, wherever the
+// destination allows it, to show the figures GT corrected them to. Posts
+// elsewhere stay as they are; the revision template is what tells those
+// subscribers, and anyone else subscribed to revisions, of the correction.
+// chart, if not nil, replaces the chart of the posts.
+func Revise(ctx context.Context, db *sql.DB, templates *Templates, dataset, date string, values map[string]int, chart *File) {
+	rows, err := db.Query(`SELECT s.id, s.type, s.url, s.locale, m.template, m.message_id, m.data
+		FROM sent_messages m JOIN webhook_subscriptions s ON s.id = m.subscription_id
+		WHERE m.dataset = $1 AND m.date = $2
+		ORDER BY s.id`, dataset, strings.TrimSpace(date))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	type post struct {
+		destination Destination
+		name, id    string
+		data        Data
+	}
+	posts := []post{}
+	for rows.Next() {
+		var p post
+		var payload []byte
+		if err := rows.Scan(&p.destination.ID, &p.destination.Type, &p.destination.URL, &p.destination.Locale, &p.name, &p.id, &payload); err != nil {
+			rows.Close()
+			log.Println(err)
+			return
+		}
+		if err := json.Unmarshal(payload, &p.data); err != nil {
+			log.Printf("error: could not read message of subscription %d: %v\n", p.destination.ID, err)
+			continue
+		}
+		posts = append(posts, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, p := range posts {
+		data := &p.data
+		if p.name == CombinedTemplate {
+			if dataset == "cases" {
+				// the combined post shows the cases at the top level too
+				data.revise(values)
+				data.Cases = &Data{}
+				*data.Cases = *data
+				data.Cases.Cases, data.Cases.Surveys = nil, nil
+			} else if data.Surveys != nil {
+				data.Surveys.revise(values)
+				data.Revised = true
+			}
+		} else {
+			data.revise(values)
+		}
+		data.Chart = chart
+
+		if err := edit(ctx, db, templates, p.destination, p.name, p.id, *data); err != nil {
+			log.Printf("error: could not edit message of subscription %d: %v\n", p.destination.ID, err)
+		}
+	}
+}
+
+// edit renders data again into the message id of d, and keeps it for
+// later corrections.
+func edit(ctx context.Context, db *sql.DB, templates *Templates, d Destination, name, id string, data Data) error {
+	if err := CheckURL(d.Type, d.URL); err != nil {
+		return err
+	}
+	n, err := d.Notifier()
+	if err != nil {
+		return err
+	}
+	e, ok := n.(Editor)
+	if !ok {
+		return nil
+	}
+
+	msg, err := templates.Render(name, d, data)
+	if err != nil {
+		return err
+	}
+
+	attempts, err := retry(ctx, func() error { return e.Edit(ctx, id, msg) })
+	logDelivery(db, d.ID, name+" (edit)", attempts, err)
+	if err != nil {
+		return err
+	}
+	return keepPost(db, d.ID, id, name, data)
+}
@@ -0,0 +1,250 @@
+package notify
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// AlertRule is the alert type of a subscription that receives the alerts
+// of rules without their own subscription list.
+const AlertRule = "rule"
+
+// Rule kinds. Every kind but positivity_above watches reported cases.
+const (
+	// reported is above Factor times the average of the Window days before
+	RuleAboveAverage = "above_average"
+	// the positivity of the latest surveillance results is above Threshold
+	// percent
+	RulePositivityAbove = "positivity_above"
+	// reported rose on each of the last Days days
+	RuleConsecutiveIncrease = "consecutive_increase"
+	// reported is higher than on any earlier day
+	RuleAllTimeHigh = "all_time_high"
+)
+
+// SeverityColors are the embed colors of each severity.
+var SeverityColors = map[string]int{
+	"info":     3447003,
+	"warning":  15105570,
+	"critical": 15158332,
+}
+
+// Rule is an alert rule, configured as a [[Rules]] table of the scrapers'
+// config.toml.
+type Rule struct {
+	Name          string
+	Kind          string
+	Factor        float64 // above_average, defaults to 2
+	Window        int     // above_average, in days, defaults to 7
+	Threshold     float64 // positivity_above, in percent
+	Days          int     // consecutive_increase, defaults to 3
+	Severity      string  // info, warning or critical, defaults to warning
+	Subscriptions []int   // IDs of the subscriptions to alert, defaults to those of the rule alert type
+}
+
+// Day is a day of a dataset as rules see it, with Values keyed as in Keys.
+type Day struct {
+	Date   string
+	Values map[string]int
+}
+
+// Alert describes a fired rule to templates. Description is written in the
+// locale of the subscription.
+type Alert struct {
+	Rule        string
+	Severity    string
+	Description string
+	Color       int
+
+	description Description
+}
+
+// Dataset is the dataset r watches.
+func (r Rule) Dataset() string {
+	if r.Kind == RulePositivityAbove {
+		return "surveys"
+	}
+	return "cases"
+}
+
+// CheckRules validates rules for the scraper of dataset and fills in their
+// defaults.
+func CheckRules(rules []Rule, dataset string) ([]Rule, error) {
+	names := make(map[string]bool)
+	checked := make([]Rule, 0, len(rules))
+
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d has no Name", i+1)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("rule %s is defined twice", r.Name)
+		}
+		names[r.Name] = true
+
+		switch r.Kind {
+		case RuleAboveAverage:
+			if r.Factor == 0 {
+				r.Factor = 2
+			}
+			if r.Window == 0 {
+				r.Window = 7
+			}
+			if r.Factor < 0 || r.Window < 1 {
+				return nil, fmt.Errorf("rule %s: Factor and Window must be positive", r.Name)
+			}
+		case RulePositivityAbove:
+			if r.Threshold <= 0 || r.Threshold >= 100 {
+				return nil, fmt.Errorf("rule %s: Threshold must be a percentage", r.Name)
+			}
+		case RuleConsecutiveIncrease:
+			if r.Days == 0 {
+				r.Days = 3
+			}
+			if r.Days < 1 {
+				return nil, fmt.Errorf("rule %s: Days must be positive", r.Name)
+			}
+		case RuleAllTimeHigh:
+		default:
+			return nil, fmt.Errorf("rule %s: Kind must be one of %s", r.Name, strings.Join([]string{
+				RuleAboveAverage, RulePositivityAbove, RuleConsecutiveIncrease, RuleAllTimeHigh,
+			}, ", "))
+		}
+
+		if r.Severity == "" {
+			r.Severity = "warning"
+		}
+		if _, ok := SeverityColors[r.Severity]; !ok {
+			return nil, fmt.Errorf("rule %s: Severity must be info, warning or critical", r.Name)
+		}
+
+		if r.Dataset() != dataset {
+			return nil, fmt.Errorf("rule %s watches %s, not %s", r.Name, r.Dataset(), dataset)
+		}
+		checked = append(checked, r)
+	}
+
+	return checked, nil
+}
+
+// Description is what a fired rule says happened, which can be written in
+// any of Locales.
+type Description struct {
+	format string
+	args   []interface{}
+}
+
+func describe(format string, args ...interface{}) Description {
+	return Description{format: format, args: args}
+}
+
+// In writes d in locale.
+func (d Description) In(locale string) string {
+	p, ok := printers[locale]
+	if !ok {
+		p = printers[DefaultLocale]
+	}
+	return p.Sprintf(d.format, d.args...)
+}
+
+// String writes d in the default locale.
+func (d Description) String() string {
+	return d.In(DefaultLocale)
+}
+
+// Evaluate checks r against days, oldest first, the last being the day
+// just scraped. It returns a description of what happened if r fires.
+func (r Rule) Evaluate(days []Day) (Description, bool) {
+	n := len(days)
+	if n == 0 {
+		return Description{}, false
+	}
+	latest := days[n-1].Values
+
+	switch r.Kind {
+	case RuleAboveAverage:
+		if n < r.Window+1 {
+			return Description{}, false
+		}
+		sum := 0
+		for _, d := range days[n-1-r.Window : n-1] {
+			sum += d.Values["reported"]
+		}
+		avg := float64(sum) / float64(r.Window)
+		if float64(latest["reported"]) > r.Factor*avg {
+			return describe("%d cases reported, more than %g× the %d-day average of %.1f",
+				latest["reported"], r.Factor, r.Window, avg), true
+		}
+
+	case RulePositivityAbove:
+		if n < 2 {
+			return Description{}, false
+		}
+		previous := days[n-2].Values
+		tests := latest["administered"] - previous["administered"]
+		if tests <= 0 {
+			return Description{}, false
+		}
+		positivity := 100 * float64(latest["positive"]-previous["positive"]) / float64(tests)
+		if positivity > r.Threshold {
+			return describe("%.2f%% of the latest %d tests were positive, above %g%%",
+				positivity, tests, r.Threshold), true
+		}
+
+	case RuleConsecutiveIncrease:
+		if n < r.Days+1 {
+			return Description{}, false
+		}
+		for i := n - r.Days; i < n; i++ {
+			if days[i].Values["reported"] <= days[i-1].Values["reported"] {
+				return Description{}, false
+			}
+		}
+		return describe("Reported cases rose %d days in a row, to %d", r.Days, latest["reported"]), true
+
+	case RuleAllTimeHigh:
+		if n < 2 {
+			return Description{}, false
+		}
+		for _, d := range days[:n-1] {
+			if d.Values["reported"] >= latest["reported"] {
+				return Description{}, false
+			}
+		}
+		return describe("%d cases reported, the most on any day so far", latest["reported"]), true
+	}
+
+	return Description{}, false
+}
+
+// Alert describes r having fired.
+func (r Rule) Alert(description Description) Alert {
+	return Alert{
+		Rule:        r.Name,
+		Severity:    r.Severity,
+		Description: description.String(),
+		Color:       SeverityColors[r.Severity],
+		description: description,
+	}
+}
+
+// Destinations returns where the alerts of r are delivered: its own
+// subscriptions if it lists any, otherwise the subscribers of the rule
+// alert type for its dataset. Paused subscriptions are left out either way.
+func (r Rule) Destinations(db *sql.DB) ([]Destination, error) {
+	if len(r.Subscriptions) == 0 {
+		return Subscribers(db, r.Dataset(), AlertRule)
+	}
+
+	rows, err := db.Query(`SELECT id, type, url, locale FROM webhook_subscriptions
+		WHERE NOT paused AND id = ANY($1)
+		ORDER BY id`, pq.Array(r.Subscriptions))
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDestinations(rows)
+}
@@ -0,0 +1,64 @@
+package notify
+
+import "testing"
+
+// reported returns days of cases with the given reported counts.
+func reported(counts ...int) []Day {
+	days := make([]Day, len(counts))
+	for i, n := range counts {
+		days[i] = Day{Values: map[string]int{"reported": n}}
+	}
+	return days
+}
+
+// surveyed returns the days of surveys with the given cumulative positive
+// and administered counts, as pairs.
+func surveyed(counts ...int) []Day {
+	days := make([]Day, len(counts)/2)
+	for i := range days {
+		days[i] = Day{Values: map[string]int{"positive": counts[2*i], "administered": counts[2*i+1]}}
+	}
+	return days
+}
+
+func TestRuleEvaluate(t *testing.T) {
+	aboveAverage := Rule{Kind: RuleAboveAverage, Factor: 2, Window: 3}
+	positivity := Rule{Kind: RulePositivityAbove, Threshold: 2}
+	increase := Rule{Kind: RuleConsecutiveIncrease, Days: 3}
+	high := Rule{Kind: RuleAllTimeHigh}
+
+	tests := []struct {
+		name      string
+		rule      Rule
+		days      []Day
+		wantFired bool
+		want      string
+	}{
+		{"no days", aboveAverage, nil, false, ""},
+		{"above average", aboveAverage, reported(1, 2, 3, 5), true, "5 cases reported, more than 2× the 3-day average of 2.0"},
+		{"at twice the average", aboveAverage, reported(1, 2, 3, 4), false, ""},
+		{"too few days for the window", aboveAverage, reported(2, 3, 50), false, ""},
+		{"thousands", aboveAverage, reported(1000, 1000, 1000, 2500), true, "2,500 cases reported, more than 2× the 3-day average of 1,000.0"},
+		{"positivity above", positivity, surveyed(10, 1000, 40, 2000), true, "3.00% of the latest 1,000 tests were positive, above 2%"},
+		{"positivity at threshold", positivity, surveyed(10, 1000, 30, 2000), false, ""},
+		{"no new tests", positivity, surveyed(10, 1000, 40, 1000), false, ""},
+		{"one survey", positivity, surveyed(40, 1000), false, ""},
+		{"consecutive increase", increase, reported(5, 1, 2, 3, 4), true, "Reported cases rose 3 days in a row, to 4"},
+		{"increase broken", increase, reported(1, 2, 2, 3, 4), false, ""},
+		{"too few days to increase", increase, reported(1, 2, 3), false, ""},
+		{"all time high", high, reported(3, 1, 4), true, "4 cases reported, the most on any day so far"},
+		{"tied high", high, reported(4, 1, 4), false, ""},
+		{"single day", high, reported(4), false, ""},
+	}
+
+	for _, tt := range tests {
+		description, fired := tt.rule.Evaluate(tt.days)
+		if fired != tt.wantFired {
+			t.Errorf("%s: fired = %v, want %v", tt.name, fired, tt.wantFired)
+			continue
+		}
+		if fired && description.String() != tt.want {
+			t.Errorf("%s: description = %q, want %q", tt.name, description.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"strings"
+)
+
+// Slack posts to a Slack incoming webhook using Block Kit. The blocks are
+// wrapped in an attachment so that the message keeps its color bar.
+type Slack struct {
+	URL string
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+	ImageURL string      `json:"image_url,omitempty"`
+	AltText  string      `json:"alt_text,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color,omitempty"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackPayload struct {
+	Username    string            `json:"username,omitempty"`
+	IconURL     string            `json:"icon_url,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+// slackEscape escapes the characters Slack treats as control sequences.
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (s Slack) Notify(ctx context.Context, msg Message) error {
+	payload := slackPayload{
+		Username: msg.Username,
+		IconURL:  msg.AvatarURL,
+		Text:     slackEscape.Replace(msg.Text),
+	}
+
+	if msg.Title != "" || len(msg.Fields) > 0 {
+		// the top-level text is what shows in notifications
+		if payload.Text == "" {
+			payload.Text = slackEscape.Replace(msg.Title)
+		}
+
+		title := "*" + slackEscape.Replace(msg.Title) + "*"
+		if msg.URL != "" {
+			title = "*<" + msg.URL + "|" + slackEscape.Replace(msg.Title) + ">*"
+		}
+		blocks := []slackBlock{{Type: "section", Text: &slackText{"mrkdwn", title}}}
+
+		if len(msg.Fields) > 0 {
+			fields := slackBlock{Type: "section"}
+			for _, f := range msg.Fields {
+				fields.Fields = append(fields.Fields, slackText{"mrkdwn", "*" + slackEscape.Replace(f.Name) + "*\n" + slackEscape.Replace(f.Value)})
+			}
+			blocks = append(blocks, fields)
+		}
+		if webImage(msg.Image) {
+			blocks = append(blocks, slackBlock{Type: "image", ImageURL: msg.Image, AltText: msg.Title})
+		}
+		if msg.Footer != "" {
+			blocks = append(blocks, slackBlock{Type: "context", Elements: []slackText{{"mrkdwn", slackEscape.Replace(msg.Footer)}}})
+		}
+
+		payload.Attachments = []slackAttachment{{Color: hexColor(msg.Color), Blocks: blocks}}
+	}
+
+	return postJSON(ctx, s.URL, payload)
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"database/sql"
+	"log"
+)
+
+// Destination is an active webhook subscription.
+type Destination struct {
+	ID     int
+	Type   string
+	URL    string
+	Locale string // one of Locales, the default if empty
+}
+
+// Notifier returns the Notifier delivering to d.
+func (d Destination) Notifier() (Notifier, error) {
+	return New(d.Type, d.URL)
+}
+
+// Subscribers returns the active destinations subscribed to alertType for
+// dataset. Subscriptions are managed through the backend's API.
+func Subscribers(db *sql.DB, dataset, alertType string) ([]Destination, error) {
+	rows, err := db.Query(`SELECT id, type, url, locale FROM webhook_subscriptions
+		WHERE NOT paused AND $1 = ANY(datasets) AND $2 = ANY(alert_types)
+		ORDER BY id`, dataset, alertType)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDestinations(rows)
+}
+
+func scanDestinations(rows *sql.Rows) ([]Destination, error) {
+	defer rows.Close()
+
+	list := []Destination{}
+	for rows.Next() {
+		var d Destination
+		if err := rows.Scan(&d.ID, &d.Type, &d.URL, &d.Locale); err != nil {
+			return nil, err
+		}
+		if err := CheckURL(d.Type, d.URL); err != nil {
+			log.Printf("error: skipping subscription %d: %v\n", d.ID, err)
+			continue
+		}
+		list = append(list, d)
+	}
+
+	return list, rows.Err()
+}
@@ -0,0 +1,88 @@
+package notify
+
+import "context"
+
+// Teams posts to a Microsoft Teams incoming webhook as an Adaptive Card.
+// Cards have no color bar, so the color is not shown.
+type Teams struct {
+	URL string
+}
+
+type teamsElement struct {
+	Type     string      `json:"type"`
+	Text     string      `json:"text,omitempty"`
+	Size     string      `json:"size,omitempty"`
+	Weight   string      `json:"weight,omitempty"`
+	IsSubtle bool        `json:"isSubtle,omitempty"`
+	Wrap     bool        `json:"wrap,omitempty"`
+	Facts    []teamsFact `json:"facts,omitempty"`
+	URL      string      `json:"url,omitempty"`
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type teamsAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+type teamsCard struct {
+	Schema  string         `json:"$schema"`
+	Type    string         `json:"type"`
+	Version string         `json:"version"`
+	Body    []teamsElement `json:"body"`
+	Actions []teamsAction  `json:"actions,omitempty"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsPayload struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+func (t Teams) Notify(ctx context.Context, msg Message) error {
+	card := teamsCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.2",
+	}
+
+	if msg.Title != "" {
+		card.Body = append(card.Body, teamsElement{Type: "TextBlock", Text: msg.Title, Size: "Medium", Weight: "Bolder", Wrap: true})
+	}
+	if msg.Text != "" {
+		card.Body = append(card.Body, teamsElement{Type: "TextBlock", Text: msg.Text, Wrap: true})
+	}
+	if len(msg.Fields) > 0 {
+		facts := teamsElement{Type: "FactSet"}
+		for _, f := range msg.Fields {
+			facts.Facts = append(facts.Facts, teamsFact{f.Name, f.Value})
+		}
+		card.Body = append(card.Body, facts)
+	}
+	if webImage(msg.Image) {
+		card.Body = append(card.Body, teamsElement{Type: "Image", URL: msg.Image})
+	}
+	if msg.Footer != "" {
+		card.Body = append(card.Body, teamsElement{Type: "TextBlock", Text: msg.Footer, Size: "Small", IsSubtle: true, Wrap: true})
+	}
+	if msg.URL != "" {
+		card.Actions = []teamsAction{{Type: "Action.OpenUrl", Title: "View source", URL: msg.URL}}
+	}
+
+	return postJSON(ctx, t.URL, teamsPayload{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	})
+}
@@ -0,0 +1,423 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Links are the GT pages each dataset is scraped from.
+var Links = map[string]string{
+	"cases":   "https://health.gatech.edu/coronavirus/health-alerts",
+	"surveys": "https://health.gatech.edu/surveillance-testing-program-results",
+}
+
+// Keys are the figures of each dataset, as found in Data.Values.
+var Keys = map[string][]string{
+	"cases":   {"reported", "total"},
+	"surveys": {"positive", "administered"},
+}
+
+// Data is what templates are executed with.
+//
+// Values holds the figures of the day, keyed as in Keys: reported and total
+// for cases, positive and administered for surveys. Previous holds the
+// figures they are compared with, which are those of the previous day in a
+// daily alert and those before the correction in a revision, and Deltas is
+// Values minus Previous. Positivity is the percentage of the tests counted
+// in Deltas that were positive. Averages holds moving averages of reported
+// cases keyed seven and thirty, and is empty when they are not known.
+// Series holds the reported cases of the last 30 days in a daily alert,
+// oldest first, and Chart an image of them for destinations that take
+// uploads. Footnotes are remarks GT printed next to the figures. Alert is
+// only set for the alert templates and Digest for the digest template. The
+// combined template is given the days of both datasets as Cases and
+// Surveys, and otherwise the fields of the cases. Revised is set when a
+// daily post is edited after GT corrected its figures. Ops is only set for
+// the ops templates, which are sent to the ops webhooks of the scrapers.
+type Data struct {
+	Dataset    string
+	Date       string // as published by GT
+	Link       string // the GT page of the dataset
+	Values     map[string]int
+	Previous   map[string]int
+	Deltas     map[string]int
+	Averages   map[string]float64
+	Positivity float64
+	Series     []int
+	Chart      *File
+	Footnotes  []string
+	Alert      Alert
+	Digest     Digest
+	Cases      *Data
+	Surveys    *Data
+	Revised    bool
+	Ops        OpsEvent
+}
+
+// Digest summarizes a week, Monday to Sunday, and compares it with the week
+// before. Average is the 7-day moving average of reported cases on the last
+// day reported in the week, and Trend an arrow from PreviousAverage to it.
+// Tests and Positives are the surveillance tests administered and found
+// positive during the week. Dates are formatted as YYYY-MM-DD.
+type Digest struct {
+	Start           string
+	End             string
+	Cases           int
+	PreviousCases   int
+	Change          int
+	PeakDate        string
+	Peak            int
+	Average         float64
+	PreviousAverage float64
+	Trend           string
+	Tests           int
+	Positives       int
+	Chart           string // link to a chart of reported cases
+}
+
+// NewData fills in the link and deltas of a day of dataset. previous may
+// be nil when there is nothing to compare with.
+func NewData(dataset, date string, values, previous map[string]int) Data {
+	d := Data{
+		Dataset:  dataset,
+		Date:     strings.TrimSpace(date),
+		Link:     Links[dataset],
+		Values:   values,
+		Previous: map[string]int{},
+		Deltas:   map[string]int{},
+		Averages: map[string]float64{},
+	}
+	for _, key := range Keys[dataset] {
+		if previous != nil {
+			d.Previous[key] = previous[key]
+			d.Deltas[key] = values[key] - previous[key]
+		}
+	}
+	if tests := d.Deltas["administered"]; tests > 0 {
+		d.Positivity = 100 * float64(d.Deltas["positive"]) / float64(tests)
+	}
+	return d
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+func sparkline(series []int) string {
+	if len(series) == 0 {
+		return ""
+	}
+	min, max := series[0], series[0]
+	for _, n := range series {
+		if n < min {
+			min = n
+		}
+		if n > max {
+			max = n
+		}
+	}
+
+	line := make([]rune, len(series))
+	for i, n := range series {
+		level := 0
+		if max > min {
+			level = (n - min) * (len(sparks) - 1) / (max - min)
+		}
+		line[i] = sparks[level]
+	}
+	return string(line)
+}
+
+// templateSource is the TOML form of a template. Every string is a
+// text/template executed with Data.
+type templateSource struct {
+	Username  string
+	AvatarURL string
+	Text      string
+	Title     string
+	URL       string
+	Color     interface{} // a number, or a template rendering one
+	Image     string
+	Footer    string
+	Fields    []struct {
+		Name   string
+		Value  string
+		Inline bool
+	}
+}
+
+type compiledField struct {
+	name, value *template.Template
+	inline      bool
+}
+
+type compiledTemplate struct {
+	username, avatarURL, text, title, url, image, footer, color *template.Template
+	fields                                                      []compiledField
+}
+
+// Templates renders messages from named templates. A template is looked up
+// by name and destination type, so that one can be written for a single
+// type and fall back to the default for the others, and is compiled once
+// for each of the Locales.
+type Templates struct {
+	set map[string]map[string]*compiledTemplate
+}
+
+// LoadTemplates compiles the default templates, then the files in dir if
+// it is set, and checks every template by executing it with sample data.
+//
+// Files are named NAME.toml or NAME.TYPE.toml, where NAME is one of the
+// default templates (cases.daily, cases.revision, cases.alert,
+// surveys.daily, surveys.revision, surveys.alert, combined, digest,
+// ops.failure and ops.recovery) and TYPE a destination type. A file
+// replaces the default of NAME, for every type or only for TYPE. Each file
+// sets Username, AvatarURL, Text, Title, URL, Color, Image, Footer and
+// [[Fields]] with Name, Value and Inline; fields that render to an empty
+// value are left out. Color is a number, or a template rendering a number
+// or a #hex code. Image is a URL, or attachment://NAME to show the uploaded
+// Chart. Besides the text/template builtins, the number and signed
+// functions format integers with thousands separators, decimal formats a
+// float with a number of decimals, and sparkline draws a list of integers
+// as text. T translates an English label into the locale of the
+// destination, formatting its arguments as printf would, and date writes
+// a date as GT publishes it in that locale.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{set: make(map[string]map[string]*compiledTemplate)}
+
+	for name, src := range defaultTemplates {
+		if err := t.add(name, src); err != nil {
+			return nil, fmt.Errorf("default template %s: %v", name, err)
+		}
+	}
+
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".toml")
+			if err := checkTemplateName(name); err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+
+			src, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if err := t.add(name, string(src)); err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// checkTemplateName rejects files that would never be used.
+func checkTemplateName(name string) error {
+	base, kind := name, ""
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		if _, ok := defaultTemplates[name]; !ok {
+			base, kind = name[:i], name[i+1:]
+		}
+	}
+
+	if _, ok := defaultTemplates[base]; !ok {
+		names := make([]string, 0, len(defaultTemplates))
+		for n := range defaultTemplates {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown template %q, must be one of %s", name, strings.Join(names, ", "))
+	}
+	if kind != "" {
+		for _, t := range Types {
+			if kind == t {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown destination type %q, must be one of %s", kind, strings.Join(Types, ", "))
+	}
+	return nil
+}
+
+// add compiles src for each locale and checks it against sample data for
+// the dataset the template name starts with.
+func (t *Templates) add(name, src string) error {
+	var s templateSource
+	if _, err := toml.Decode(src, &s); err != nil {
+		return err
+	}
+
+	locales := make(map[string]*compiledTemplate)
+	for _, locale := range Locales {
+		c, err := compile(name, locale, s)
+		if err != nil {
+			return fmt.Errorf("%s: %v", locale, err)
+		}
+		locales[locale] = c
+	}
+	t.set[name] = locales
+	return nil
+}
+
+// compile parses s with the template functions of locale.
+func compile(name, locale string, s templateSource) (*compiledTemplate, error) {
+	funcs := localeFuncs(locale)
+	c := &compiledTemplate{}
+	var err error
+	parse := func(part, text string) *template.Template {
+		if err != nil {
+			return nil
+		}
+		var tmpl *template.Template
+		tmpl, err = template.New(name + "." + part).Funcs(funcs).Option("missingkey=error").Parse(text)
+		return tmpl
+	}
+
+	c.username = parse("username", s.Username)
+	c.avatarURL = parse("avatarurl", s.AvatarURL)
+	c.text = parse("text", s.Text)
+	c.title = parse("title", s.Title)
+	c.url = parse("url", s.URL)
+	c.image = parse("image", s.Image)
+	c.footer = parse("footer", s.Footer)
+	switch color := s.Color.(type) {
+	case nil:
+		c.color = parse("color", "")
+	case int64:
+		c.color = parse("color", strconv.FormatInt(color, 10))
+	case string:
+		c.color = parse("color", color)
+	default:
+		return nil, fmt.Errorf("Color must be a number or a template")
+	}
+	for i, f := range s.Fields {
+		c.fields = append(c.fields, compiledField{
+			name:   parse(fmt.Sprintf("fields[%d].name", i), f.Name),
+			value:  parse(fmt.Sprintf("fields[%d].value", i), f.Value),
+			inline: f.Inline,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a misspelt key is caught here, while sending it renders as zero
+	// rather than losing the whole message
+	dataset := strings.SplitN(name, ".", 2)[0]
+	if _, err := c.render(sampleData(dataset)); err != nil {
+		return nil, err
+	}
+	for _, tmpl := range c.all() {
+		tmpl.Option("missingkey=zero")
+	}
+	return c, nil
+}
+
+func (c *compiledTemplate) all() []*template.Template {
+	list := []*template.Template{c.username, c.avatarURL, c.text, c.title, c.url, c.image, c.footer, c.color}
+	for _, f := range c.fields {
+		list = append(list, f.name, f.value)
+	}
+	return list
+}
+
+// sampleData has every key of dataset set, for checking templates.
+func sampleData(dataset string) Data {
+	values, previous := map[string]int{}, map[string]int{}
+	for i, key := range Keys[dataset] {
+		values[key], previous[key] = 1200+i, 1000+i
+	}
+
+	d := NewData(dataset, "January 1, 2021", values, previous)
+	d.Averages["seven"], d.Averages["thirty"] = 12.5, 10.25
+	d.Series = []int{3, 5, 4, 8}
+	d.Chart = &File{Name: "chart.png", ContentType: "image/png"}
+	d.Footnotes = []string{"sample footnote"}
+	d.Alert = Alert{Rule: "sample", Severity: "warning", Description: "sample alert", Color: SeverityColors["warning"]}
+	d.Digest = Digest{
+		Start: "2020-12-28", End: "2021-01-03", Cases: 70, PreviousCases: 50, Change: 20,
+		PeakDate: "2020-12-30", Peak: 20, Average: 10, PreviousAverage: 7.1, Trend: "↑",
+		Tests: 5000, Positives: 25, Chart: Links["cases"],
+	}
+	d.Ops = OpsEvent{Source: "health-alerts", Check: "fetch", Error: "sample error", Since: "Jan 1 09:00 EST"}
+	if dataset == CombinedTemplate {
+		cases, surveys := sampleData("cases"), sampleData("surveys")
+		d.Cases, d.Surveys = &cases, &surveys
+	}
+	return d
+}
+
+func (c *compiledTemplate) render(data Data) (Message, error) {
+	var err error
+	exec := func(tmpl *template.Template) string {
+		if err != nil {
+			return ""
+		}
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, data)
+		return buf.String()
+	}
+
+	msg := Message{
+		Username:  exec(c.username),
+		AvatarURL: exec(c.avatarURL),
+		Text:      exec(c.text),
+		Title:     exec(c.title),
+		URL:       exec(c.url),
+		Image:     exec(c.image),
+		Footer:    exec(c.footer),
+	}
+	if data.Chart != nil && msg.Image == "attachment://"+data.Chart.Name {
+		msg.Files = []File{*data.Chart}
+	}
+	if color := strings.TrimSpace(exec(c.color)); color != "" && err == nil {
+		var n int64
+		if strings.HasPrefix(color, "#") {
+			n, err = strconv.ParseInt(color[1:], 16, 32)
+		} else {
+			n, err = strconv.ParseInt(color, 10, 32)
+		}
+		msg.Color = int(n)
+	}
+	for _, f := range c.fields {
+		field := Field{Name: exec(f.name), Value: exec(f.value), Inline: f.inline}
+		if strings.TrimSpace(field.Value) != "" {
+			msg.Fields = append(msg.Fields, field)
+		}
+	}
+	return msg, err
+}
+
+// Render executes the template name for d, preferring the one written for
+// its type, in its locale. Only Discord is given the chart, the others
+// render as if there were none.
+func (t *Templates) Render(name string, d Destination, data Data) (Message, error) {
+	if d.Type != TypeDiscord {
+		data.Chart = nil
+	}
+	if data.Alert.description.format != "" {
+		data.Alert.Description = data.Alert.description.In(d.Locale)
+	}
+
+	locales, ok := t.set[name+"."+d.Type]
+	if !ok {
+		if locales, ok = t.set[name]; !ok {
+			return Message{}, fmt.Errorf("no template named %s", name)
+		}
+	}
+	c, ok := locales[d.Locale]
+	if !ok {
+		c = locales[DefaultLocale]
+	}
+	return c.render(data)
+}
@@ -0,0 +1,784 @@
+package main
+
+import (
+	"net/http"
+)
+
+// openAPISpec describes every route served by the backend. Keep it in step
+// with the router in main.go and with the client package.
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "GT JPJ Tracking API",
+    "description": "Georgia Institute of Technology JPJ Tracking API - Not affiliated with Georgia Tech. API keys are optional and raise the rate limit; every response carries X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers.",
+    "version": "2.0.0"
+  },
+  "servers": [
+    {"url": "https://api.aditya.diwakar.io"}
+  ],
+  "paths": {
+    "/healthz": {
+      "get": {
+        "operationId": "healthz",
+        "tags": ["operations"],
+        "description": "Answers 200 as long as the process is serving requests. Not rate limited.",
+        "responses": {
+          "200": {"description": "Alive"}
+        }
+      }
+    },
+    "/readyz": {
+      "get": {
+        "operationId": "readyz",
+        "tags": ["operations"],
+        "description": "Checks that Postgres and Redis are reachable and that the dataset was refreshed within two refresh intervals. Not rate limited.",
+        "responses": {
+          "200": {"description": "Ready", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ReadyEnvelope"}}}},
+          "503": {"description": "Not ready; the failing checks carry the reason", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ReadyEnvelope"}}}}
+        }
+      }
+    },
+    "/metrics": {
+      "get": {
+        "operationId": "metrics",
+        "tags": ["operations"],
+        "description": "Prometheus metrics. Not rate limited.",
+        "responses": {
+          "200": {"description": "Metrics in the Prometheus text format", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/gt-jpj": {
+      "get": {
+        "operationId": "legacyHome",
+        "tags": ["legacy"],
+        "responses": {
+          "200": {"description": "Welcome message", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LegacyStringResponse"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/cases": {
+      "get": {
+        "operationId": "legacyListCases",
+        "tags": ["legacy"],
+        "description": "Every reported day. IDs are renumbered after deduplication and are not stable; use /gt-jpj/v2/cases instead.",
+        "responses": {
+          "200": {"description": "Reported cases", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LegacyCaseResponse"}}}},
+          "304": {"$ref": "#/components/responses/NotModified"},
+          "500": {"description": "Internal Server Error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LegacyStringResponse"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/testing": {
+      "get": {
+        "operationId": "legacyListSurveys",
+        "tags": ["legacy"],
+        "description": "Every surveillance testing result. IDs are renumbered after deduplication and are not stable; use /gt-jpj/v2/testing instead.",
+        "responses": {
+          "200": {"description": "Surveillance testing results", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LegacySurveyResponse"}}}},
+          "304": {"$ref": "#/components/responses/NotModified"},
+          "500": {"description": "Internal Server Error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LegacyStringResponse"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/feed.atom": {
+      "get": {
+        "operationId": "getAtomFeed",
+        "tags": ["feeds"],
+        "description": "The latest 100 case days and surveillance results, newest first, with the figures of the Discord alerts. Entry IDs are stable; a revised entry keeps its ID, gets a new updated time and is marked (Revised).",
+        "responses": {
+          "200": {"description": "Atom 1.0 feed", "content": {"application/atom+xml": {"schema": {"type": "string"}}}},
+          "304": {"$ref": "#/components/responses/NotModified"},
+          "500": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/feed.rss": {
+      "get": {
+        "operationId": "getRSSFeed",
+        "tags": ["feeds"],
+        "description": "The latest 100 case days and surveillance results, newest first, with the figures of the Discord alerts. Entry IDs are stable; a revised entry keeps its ID, gets a new updated time and is marked (Revised).",
+        "responses": {
+          "200": {"description": "RSS 2.0 feed", "content": {"application/rss+xml": {"schema": {"type": "string"}}}},
+          "304": {"$ref": "#/components/responses/NotModified"},
+          "500": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/badge/{metric}.svg": {
+      "get": {
+        "operationId": "getBadge",
+        "tags": ["feeds"],
+        "description": "A shields-style SVG badge with the latest value of a metric, for embedding in READMEs and wikis. Shows \"no data\" in grey when there is nothing to show.",
+        "parameters": [
+          {"name": "metric", "in": "path", "required": true, "schema": {"type": "string", "enum": ["reported", "total", "average", "positivity"]}, "description": "Cases reported today, total cases, 7-day average of reported cases, or surveillance positivity in percent."},
+          {"name": "label", "in": "query", "required": false, "schema": {"type": "string", "maxLength": 64}, "description": "Text on the left of the badge. Defaults to a name for the metric."},
+          {"name": "color", "in": "query", "required": false, "schema": {"type": "string", "default": "brightgreen"}, "description": "Color of the value: brightgreen, green, yellowgreen, yellow, orange, red, blue, lightgrey, grey or a hex code without #."},
+          {"name": "thresholds", "in": "query", "required": false, "schema": {"type": "string"}, "example": "10:yellow,25:orange,50:red", "description": "value:color pairs; the color of the highest threshold the value reaches replaces color."}
+        ],
+        "responses": {
+          "200": {"description": "SVG badge", "content": {"image/svg+xml": {"schema": {"type": "string"}}}},
+          "304": {"$ref": "#/components/responses/NotModified"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/openapi.json": {
+      "get": {
+        "operationId": "getOpenAPI",
+        "tags": ["meta"],
+        "responses": {
+          "200": {"description": "This document", "content": {"application/json": {}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/events": {
+      "get": {
+        "operationId": "streamEvents",
+        "tags": ["live"],
+        "description": "Server-Sent Events stream with one event per new case day, surveillance result, revision or fired alert rule. The SSE event name is the event kind and the data is an Event. Reconnect with Last-Event-ID to receive missed events.",
+        "parameters": [
+          {"name": "Last-Event-ID", "in": "header", "required": false, "schema": {"type": "integer"}}
+        ],
+        "responses": {
+          "200": {"description": "Event stream", "content": {"text/event-stream": {"schema": {"$ref": "#/components/schemas/Event"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/ws": {
+      "get": {
+        "operationId": "serveWebSocket",
+        "tags": ["live"],
+        "description": "WebSocket feed. Send {\"type\": \"subscribe\", \"channel\": \"cases\"} (or unsubscribe) for any of the channels cases, testing, revisions and alerts. Each subscription is answered with a snapshot message followed by an update message per new Event. The server pings every 54 seconds and disconnects clients that fall behind.",
+        "responses": {
+          "101": {"description": "Switching to the WebSocket protocol"},
+          "400": {"description": "Not a WebSocket handshake"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/graphql": {
+      "get": {
+        "operationId": "queryGraphQLGet",
+        "tags": ["graphql"],
+        "parameters": [
+          {"name": "query", "in": "query", "required": true, "schema": {"type": "string"}},
+          {"name": "operationName", "in": "query", "schema": {"type": "string"}},
+          {"name": "variables", "in": "query", "description": "JSON object", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/GraphQL"},
+          "400": {"$ref": "#/components/responses/GraphQL"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      },
+      "post": {
+        "operationId": "queryGraphQL",
+        "tags": ["graphql"],
+        "description": "GraphQL over case days, surveillance results, derived stats and revisions. Queries deeper than 6 levels or with an estimated complexity above 10000 are rejected with 400. Lists return at most 1000 items.",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GraphQLRequest"}}}
+        },
+        "responses": {
+          "200": {"$ref": "#/components/responses/GraphQL"},
+          "400": {"$ref": "#/components/responses/GraphQL"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/v2": {
+      "get": {
+        "operationId": "getIndex",
+        "tags": ["v2"],
+        "responses": {
+          "200": {"description": "API information", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IndexEnvelope"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/v2/cases": {
+      "get": {
+        "operationId": "listCases",
+        "tags": ["v2"],
+        "responses": {
+          "200": {"description": "Reported cases", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CaseListEnvelope"}}}},
+          "304": {"$ref": "#/components/responses/NotModified"},
+          "500": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/v2/cases/{date}": {
+      "get": {
+        "operationId": "getCase",
+        "tags": ["v2"],
+        "parameters": [{"$ref": "#/components/parameters/Date"}],
+        "responses": {
+          "200": {"description": "Cases reported on date", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CaseEnvelope"}}}},
+          "304": {"$ref": "#/components/responses/NotModified"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/v2/testing": {
+      "get": {
+        "operationId": "listSurveys",
+        "tags": ["v2"],
+        "responses": {
+          "200": {"description": "Surveillance testing results", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SurveyListEnvelope"}}}},
+          "304": {"$ref": "#/components/responses/NotModified"},
+          "500": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/v2/testing/{date}": {
+      "get": {
+        "operationId": "getSurvey",
+        "tags": ["v2"],
+        "parameters": [{"$ref": "#/components/parameters/Date"}],
+        "responses": {
+          "200": {"description": "Surveillance testing result for date", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SurveyEnvelope"}}}},
+          "304": {"$ref": "#/components/responses/NotModified"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/gt-jpj/admin/audit": {
+      "get": {
+        "operationId": "adminAuditLog",
+        "tags": ["admin"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "parameters": [
+          {"name": "dataset", "in": "query", "schema": {"type": "string", "enum": ["cases", "surveys"]}},
+          {"name": "date", "in": "query", "schema": {"type": "string", "format": "date"}},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 1000, "default": 100}}
+        ],
+        "responses": {
+          "200": {"description": "Latest admin changes, newest first", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AuditEnvelope"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/gt-jpj/admin/cases": {
+      "post": {
+        "operationId": "adminCreateCase",
+        "tags": ["admin"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AdminRequest"}}}},
+        "responses": {
+          "200": {"description": "The day as now served", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CaseEnvelope"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/gt-jpj/admin/cases/{date}": {
+      "patch": {
+        "operationId": "adminCorrectCase",
+        "tags": ["admin"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "parameters": [{"$ref": "#/components/parameters/Date"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AdminRequest"}}}},
+        "responses": {
+          "200": {"description": "The day as now served", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CaseEnvelope"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/gt-jpj/admin/cases/{date}/hide": {
+      "post": {
+        "operationId": "adminHideCase",
+        "tags": ["admin"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "parameters": [{"$ref": "#/components/parameters/Date"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AdminRequest"}}}},
+        "responses": {
+          "204": {"description": "The day is no longer served"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/gt-jpj/admin/testing": {
+      "post": {
+        "operationId": "adminCreateSurvey",
+        "tags": ["admin"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AdminRequest"}}}},
+        "responses": {
+          "200": {"description": "The result as now served", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SurveyEnvelope"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/gt-jpj/admin/testing/{date}": {
+      "patch": {
+        "operationId": "adminCorrectSurvey",
+        "tags": ["admin"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "parameters": [{"$ref": "#/components/parameters/Date"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AdminRequest"}}}},
+        "responses": {
+          "200": {"description": "The result as now served", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SurveyEnvelope"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/gt-jpj/admin/testing/{date}/hide": {
+      "post": {
+        "operationId": "adminHideSurvey",
+        "tags": ["admin"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "parameters": [{"$ref": "#/components/parameters/Date"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AdminRequest"}}}},
+        "responses": {
+          "204": {"description": "The result is no longer served"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/gt-jpj/subscriptions": {
+      "get": {
+        "operationId": "listSubscriptions",
+        "tags": ["subscriptions"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "responses": {
+          "200": {"description": "Subscriptions registered with the key, or every subscription for an admin key", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SubscriptionListEnvelope"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "createSubscription",
+        "tags": ["subscriptions"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "description": "Registers a Discord, Slack, Microsoft Teams, Google Chat or generic JSON webhook. Notifications are rendered in the format of the destination type. A verification message is posted to it first, and the subscription is only stored if that succeeds.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SubscriptionRequest"}}}},
+        "responses": {
+          "201": {"description": "Registered subscription", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SubscriptionEnvelope"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "422": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/gt-jpj/subscriptions/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}],
+      "get": {
+        "operationId": "getSubscription",
+        "tags": ["subscriptions"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "responses": {
+          "200": {"description": "Subscription", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SubscriptionEnvelope"}}}},
+          "404": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "operationId": "updateSubscription",
+        "tags": ["subscriptions"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "description": "Renames, pauses, resumes or changes the options of a subscription. Only the fields sent are changed; the URL and type cannot be.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SubscriptionRequest"}}}},
+        "responses": {
+          "200": {"description": "Updated subscription", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SubscriptionEnvelope"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteSubscription",
+        "tags": ["subscriptions"],
+        "security": [{"ApiKeyHeader": []}, {"BearerKey": []}],
+        "responses": {
+          "204": {"description": "Subscription deleted"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "429": {"$ref": "#/components/responses/RateLimited"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  },
+  "security": [{}, {"ApiKeyHeader": []}, {"BearerKey": []}, {"ApiKeyQuery": []}],
+  "components": {
+    "securitySchemes": {
+      "ApiKeyHeader": {"type": "apiKey", "in": "header", "name": "X-API-Key"},
+      "BearerKey": {"type": "http", "scheme": "bearer"},
+      "ApiKeyQuery": {"type": "apiKey", "in": "query", "name": "api_key"}
+    },
+    "parameters": {
+      "Date": {
+        "name": "date",
+        "in": "path",
+        "required": true,
+        "schema": {"type": "string", "format": "date"}
+      }
+    },
+    "responses": {
+      "GraphQL": {
+        "description": "GraphQL result",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GraphQLResponse"}}}
+      },
+      "Unauthorized": {
+        "description": "The API key is invalid or has been revoked",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorEnvelope"}}}
+      },
+      "RateLimited": {
+        "description": "The rate limit of the key or client IP is exhausted; retry after Retry-After seconds",
+        "headers": {
+          "Retry-After": {"schema": {"type": "integer"}, "description": "Seconds until a request will be allowed"}
+        },
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorEnvelope"}}}
+      },
+      "NotModified": {
+        "description": "The dataset has not changed since the ETag or Last-Modified the client sent"
+      },
+      "Error": {
+        "description": "Error",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorEnvelope"}}}
+      }
+    },
+    "schemas": {
+      "LegacyStringResponse": {
+        "type": "object",
+        "properties": {
+          "payload": {"type": "string"},
+          "status_code": {"type": "integer"}
+        }
+      },
+      "LegacyCaseResponse": {
+        "type": "object",
+        "properties": {
+          "payload": {"type": "array", "items": {"$ref": "#/components/schemas/LegacyCasesRow"}},
+          "status_code": {"type": "integer"}
+        }
+      },
+      "LegacyCasesRow": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "date": {"type": "string", "example": "August 25, 2020"},
+          "reported": {"type": "integer"},
+          "total": {"type": "integer"},
+          "corrected": {"type": "boolean", "description": "Entered or corrected by an admin rather than taken from GT as published"}
+        }
+      },
+      "LegacySurveyResponse": {
+        "type": "object",
+        "properties": {
+          "payload": {"type": "array", "items": {"$ref": "#/components/schemas/LegacySurveysRow"}},
+          "status_code": {"type": "integer"}
+        }
+      },
+      "LegacySurveysRow": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "date": {"type": "string"},
+          "positive": {"type": "integer"},
+          "administered": {"type": "integer"}
+        }
+      },
+      "CaseDay": {
+        "type": "object",
+        "required": ["id", "date", "reported", "total"],
+        "properties": {
+          "id": {"type": "integer", "description": "Stable identifier"},
+          "date": {"type": "string", "format": "date"},
+          "reported": {"type": "integer"},
+          "total": {"type": "integer"}
+        }
+      },
+      "SurveyDay": {
+        "type": "object",
+        "required": ["id", "date", "positive", "administered"],
+        "properties": {
+          "id": {"type": "integer", "description": "Stable identifier"},
+          "date": {"type": "string", "format": "date"},
+          "positive": {"type": "integer"},
+          "administered": {"type": "integer"},
+          "corrected": {"type": "boolean", "description": "Entered or corrected by an admin rather than taken from GT as published"}
+        }
+      },
+      "AdminRequest": {
+        "type": "object",
+        "required": ["reason"],
+        "description": "Cases take reported and total, surveillance results take positive and administered. Creating a day needs both, correcting needs at least one, hiding needs neither.",
+        "properties": {
+          "date": {"type": "string", "format": "date", "description": "Only when creating a day"},
+          "reason": {"type": "string"},
+          "reported": {"type": "integer", "minimum": 0},
+          "total": {"type": "integer", "minimum": 0},
+          "positive": {"type": "integer", "minimum": 0},
+          "administered": {"type": "integer", "minimum": 0}
+        }
+      },
+      "AuditEntry": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "key_id": {"type": "integer"},
+          "actor": {"type": "string", "description": "Name of the API key used"},
+          "action": {"type": "string", "enum": ["create", "correct", "hide"]},
+          "dataset": {"type": "string", "enum": ["cases", "surveys"]},
+          "date": {"type": "string", "format": "date"},
+          "before": {"type": "object", "nullable": true},
+          "after": {"type": "object", "nullable": true},
+          "reason": {"type": "string"},
+          "created_at": {"type": "string", "format": "date-time"}
+        }
+      },
+      "AuditEnvelope": {
+        "type": "object",
+        "properties": {
+          "data": {"type": "array", "items": {"$ref": "#/components/schemas/AuditEntry"}},
+          "meta": {"$ref": "#/components/schemas/ListMeta"},
+          "error": {"nullable": true}
+        }
+      },
+      "Subscription": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "name": {"type": "string"},
+          "type": {"type": "string", "enum": ["discord", "slack", "teams", "googlechat", "json"]},
+          "url": {"type": "string", "format": "uri"},
+          "datasets": {"type": "array", "items": {"type": "string", "enum": ["cases", "surveys"]}},
+          "alert_types": {"type": "array", "items": {"type": "string", "enum": ["daily", "revision", "rule", "weekly"]}},
+          "locale": {"type": "string", "enum": ["en", "es", "zh"]},
+          "paused": {"type": "boolean"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "verified_at": {"type": "string", "format": "date-time"}
+        }
+      },
+      "SubscriptionRequest": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string", "description": "Defaults to the name of the API key"},
+          "type": {"type": "string", "enum": ["discord", "slack", "teams", "googlechat", "json"], "default": "discord", "description": "How notifications are rendered: a Discord embed, Slack Block Kit, a Teams Adaptive Card, a Google Chat card, or the message as plain JSON"},
+          "url": {"type": "string", "format": "uri", "description": "An https incoming webhook URL of the service named by type, or any public https URL for json; required on registration"},
+          "datasets": {"type": "array", "items": {"type": "string", "enum": ["cases", "surveys"]}, "description": "Defaults to every dataset"},
+          "alert_types": {"type": "array", "items": {"type": "string", "enum": ["daily", "revision", "rule", "weekly"]}, "description": "daily for each newly published day, revision when GT corrects one, rule when an alert rule without its own subscription list fires, weekly for the digest of each week; defaults to all but weekly"},
+          "locale": {"type": "string", "enum": ["en", "es", "zh"], "default": "en", "description": "Language of the posts: English, Spanish or Simplified Chinese labels, numbers and dates"},
+          "paused": {"type": "boolean"}
+        }
+      },
+      "SubscriptionEnvelope": {
+        "type": "object",
+        "properties": {
+          "data": {"$ref": "#/components/schemas/Subscription"},
+          "meta": {"nullable": true},
+          "error": {"nullable": true}
+        }
+      },
+      "SubscriptionListEnvelope": {
+        "type": "object",
+        "properties": {
+          "data": {"type": "array", "items": {"$ref": "#/components/schemas/Subscription"}},
+          "meta": {"$ref": "#/components/schemas/ListMeta"},
+          "error": {"nullable": true}
+        }
+      },
+      "ReadyEnvelope": {
+        "type": "object",
+        "properties": {
+          "data": {
+            "type": "object",
+            "properties": {
+              "ready": {"type": "boolean"},
+              "checks": {
+                "type": "object",
+                "properties": {
+                  "database": {"type": "string"},
+                  "redis": {"type": "string"},
+                  "dataset": {"type": "string"}
+                },
+                "description": "\"ok\" or why the check failed"
+              }
+            }
+          },
+          "meta": {"nullable": true},
+          "error": {"nullable": true}
+        }
+      },
+      "Event": {
+        "type": "object",
+        "required": ["id", "kind", "date", "data", "created_at"],
+        "properties": {
+          "id": {"type": "integer"},
+          "kind": {"type": "string", "enum": ["case", "survey", "revision", "alert"]},
+          "date": {"type": "string", "format": "date"},
+          "data": {"type": "object", "description": "The stored figures; for a revision the dataset name and the values before and after; for an alert the dataset, rule, severity and description along with the figures of the day"},
+          "created_at": {"type": "string", "format": "date-time"}
+        }
+      },
+      "GraphQLRequest": {
+        "type": "object",
+        "required": ["query"],
+        "properties": {
+          "query": {"type": "string"},
+          "operationName": {"type": "string"},
+          "variables": {"type": "object"}
+        }
+      },
+      "GraphQLResponse": {
+        "type": "object",
+        "properties": {
+          "data": {"type": "object", "nullable": true},
+          "errors": {"type": "array", "items": {"type": "object", "properties": {"message": {"type": "string"}}}}
+        }
+      },
+      "Index": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "version": {"type": "string"}
+        }
+      },
+      "ListMeta": {
+        "type": "object",
+        "properties": {
+          "count": {"type": "integer"}
+        }
+      },
+      "APIError": {
+        "type": "object",
+        "required": ["code", "message"],
+        "properties": {
+          "code": {"type": "string", "enum": ["bad_request", "not_found", "method_not_allowed", "internal"]},
+          "message": {"type": "string"}
+        }
+      },
+      "ErrorEnvelope": {
+        "type": "object",
+        "properties": {
+          "data": {"nullable": true},
+          "meta": {"nullable": true},
+          "error": {"$ref": "#/components/schemas/APIError"}
+        }
+      },
+      "IndexEnvelope": {
+        "type": "object",
+        "properties": {
+          "data": {"$ref": "#/components/schemas/Index"},
+          "meta": {"nullable": true},
+          "error": {"nullable": true}
+        }
+      },
+      "CaseListEnvelope": {
+        "type": "object",
+        "properties": {
+          "data": {"type": "array", "items": {"$ref": "#/components/schemas/CaseDay"}},
+          "meta": {"$ref": "#/components/schemas/ListMeta"},
+          "error": {"nullable": true}
+        }
+      },
+      "CaseEnvelope": {
+        "type": "object",
+        "properties": {
+          "data": {"$ref": "#/components/schemas/CaseDay"},
+          "meta": {"nullable": true},
+          "error": {"nullable": true}
+        }
+      },
+      "SurveyListEnvelope": {
+        "type": "object",
+        "properties": {
+          "data": {"type": "array", "items": {"$ref": "#/components/schemas/SurveyDay"}},
+          "meta": {"$ref": "#/components/schemas/ListMeta"},
+          "error": {"nullable": true}
+        }
+      },
+      "SurveyEnvelope": {
+        "type": "object",
+        "properties": {
+          "data": {"$ref": "#/components/schemas/SurveyDay"},
+          "meta": {"nullable": true},
+          "error": {"nullable": true}
+        }
+      }
+    }
+  }
+}
+`
+
+func getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write([]byte(openAPISpec))
+}
@@ -0,0 +1,5 @@
+// Package pb holds the protobuf definition of the backend's gRPC service
+// and the code generated from it.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative gtjpj.proto
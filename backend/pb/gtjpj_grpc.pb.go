@@ -0,0 +1,311 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion7
+
+// TrackingClient is the client API for Tracking service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TrackingClient interface {
+	ListCases(ctx context.Context, in *ListCasesRequest, opts ...grpc.CallOption) (*ListCasesResponse, error)
+	GetCase(ctx context.Context, in *GetCaseRequest, opts ...grpc.CallOption) (*CaseDay, error)
+	ListSurveys(ctx context.Context, in *ListSurveysRequest, opts ...grpc.CallOption) (*ListSurveysResponse, error)
+	GetSurvey(ctx context.Context, in *GetSurveyRequest, opts ...grpc.CallOption) (*SurveyDay, error)
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*Stats, error)
+	// Watch streams every event stored after the request is made, or after
+	// after_id when it is set. A client that falls behind is disconnected
+	// with RESOURCE_EXHAUSTED and should resume from the last ID it saw.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Tracking_WatchClient, error)
+}
+
+type trackingClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTrackingClient(cc grpc.ClientConnInterface) TrackingClient {
+	return &trackingClient{cc}
+}
+
+func (c *trackingClient) ListCases(ctx context.Context, in *ListCasesRequest, opts ...grpc.CallOption) (*ListCasesResponse, error) {
+	out := new(ListCasesResponse)
+	err := c.cc.Invoke(ctx, "/gtjpj.v1.Tracking/ListCases", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trackingClient) GetCase(ctx context.Context, in *GetCaseRequest, opts ...grpc.CallOption) (*CaseDay, error) {
+	out := new(CaseDay)
+	err := c.cc.Invoke(ctx, "/gtjpj.v1.Tracking/GetCase", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trackingClient) ListSurveys(ctx context.Context, in *ListSurveysRequest, opts ...grpc.CallOption) (*ListSurveysResponse, error) {
+	out := new(ListSurveysResponse)
+	err := c.cc.Invoke(ctx, "/gtjpj.v1.Tracking/ListSurveys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trackingClient) GetSurvey(ctx context.Context, in *GetSurveyRequest, opts ...grpc.CallOption) (*SurveyDay, error) {
+	out := new(SurveyDay)
+	err := c.cc.Invoke(ctx, "/gtjpj.v1.Tracking/GetSurvey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trackingClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*Stats, error) {
+	out := new(Stats)
+	err := c.cc.Invoke(ctx, "/gtjpj.v1.Tracking/GetStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trackingClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Tracking_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Tracking_serviceDesc.Streams[0], "/gtjpj.v1.Tracking/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trackingWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Tracking_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type trackingWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *trackingWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TrackingServer is the server API for Tracking service.
+// All implementations must embed UnimplementedTrackingServer
+// for forward compatibility
+type TrackingServer interface {
+	ListCases(context.Context, *ListCasesRequest) (*ListCasesResponse, error)
+	GetCase(context.Context, *GetCaseRequest) (*CaseDay, error)
+	ListSurveys(context.Context, *ListSurveysRequest) (*ListSurveysResponse, error)
+	GetSurvey(context.Context, *GetSurveyRequest) (*SurveyDay, error)
+	GetStats(context.Context, *GetStatsRequest) (*Stats, error)
+	// Watch streams every event stored after the request is made, or after
+	// after_id when it is set. A client that falls behind is disconnected
+	// with RESOURCE_EXHAUSTED and should resume from the last ID it saw.
+	Watch(*WatchRequest, Tracking_WatchServer) error
+	mustEmbedUnimplementedTrackingServer()
+}
+
+// UnimplementedTrackingServer must be embedded to have forward compatible implementations.
+type UnimplementedTrackingServer struct {
+}
+
+func (UnimplementedTrackingServer) ListCases(context.Context, *ListCasesRequest) (*ListCasesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCases not implemented")
+}
+func (UnimplementedTrackingServer) GetCase(context.Context, *GetCaseRequest) (*CaseDay, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCase not implemented")
+}
+func (UnimplementedTrackingServer) ListSurveys(context.Context, *ListSurveysRequest) (*ListSurveysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSurveys not implemented")
+}
+func (UnimplementedTrackingServer) GetSurvey(context.Context, *GetSurveyRequest) (*SurveyDay, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSurvey not implemented")
+}
+func (UnimplementedTrackingServer) GetStats(context.Context, *GetStatsRequest) (*Stats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedTrackingServer) Watch(*WatchRequest, Tracking_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedTrackingServer) mustEmbedUnimplementedTrackingServer() {}
+
+// UnsafeTrackingServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TrackingServer will
+// result in compilation errors.
+type UnsafeTrackingServer interface {
+	mustEmbedUnimplementedTrackingServer()
+}
+
+func RegisterTrackingServer(s grpc.ServiceRegistrar, srv TrackingServer) {
+	s.RegisterService(&_Tracking_serviceDesc, srv)
+}
+
+func _Tracking_ListCases_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCasesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrackingServer).ListCases(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gtjpj.v1.Tracking/ListCases",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrackingServer).ListCases(ctx, req.(*ListCasesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tracking_GetCase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrackingServer).GetCase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gtjpj.v1.Tracking/GetCase",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrackingServer).GetCase(ctx, req.(*GetCaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tracking_ListSurveys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSurveysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrackingServer).ListSurveys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gtjpj.v1.Tracking/ListSurveys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrackingServer).ListSurveys(ctx, req.(*ListSurveysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tracking_GetSurvey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSurveyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrackingServer).GetSurvey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gtjpj.v1.Tracking/GetSurvey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrackingServer).GetSurvey(ctx, req.(*GetSurveyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tracking_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrackingServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gtjpj.v1.Tracking/GetStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrackingServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tracking_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrackingServer).Watch(m, &trackingWatchServer{stream})
+}
+
+type Tracking_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type trackingWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *trackingWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Tracking_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "gtjpj.v1.Tracking",
+	HandlerType: (*TrackingServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListCases",
+			Handler:    _Tracking_ListCases_Handler,
+		},
+		{
+			MethodName: "GetCase",
+			Handler:    _Tracking_GetCase_Handler,
+		},
+		{
+			MethodName: "ListSurveys",
+			Handler:    _Tracking_ListSurveys_Handler,
+		},
+		{
+			MethodName: "GetSurvey",
+			Handler:    _Tracking_GetSurvey_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _Tracking_GetStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Tracking_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "gtjpj.proto",
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Default limits, in requests per minute, used when RateLimit leaves them
+// unset. Each bucket holds a minute's worth of tokens, so a client may burst
+// up to its whole limit at once.
+const (
+	defaultAnonymousRate = 60
+	defaultKeyRate       = 600
+)
+
+// usageRetention is how long daily usage counts are kept.
+const usageRetention = 90 * 24 * time.Hour
+
+type rateLimitConfig struct {
+	Anonymous int // requests per minute per IP without a key
+	Key       int // requests per minute per key, unless the key sets its own
+
+	// TrustProxy takes the client IP from X-Forwarded-For or X-Real-IP.
+	// Only enable it behind a proxy that overwrites those headers.
+	TrustProxy bool
+	Disabled   bool
+}
+
+// tokenBucket refills KEYS[1] at ARGV[1] tokens per second up to ARGV[2]
+// and takes one token if available. It returns whether the request is
+// allowed, the tokens left, and the milliseconds until the bucket is full
+// and until the next token is available. Doing this in one script keeps the
+// bucket consistent across instances.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+local full = math.ceil((burst - tokens) * 1000 / rate)
+local retry = 0
+if allowed == 0 then
+	retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], full + 1000)
+
+return {allowed, math.floor(tokens), full, retry}
+`)
+
+type apiKeyContextKey struct{}
+
+// requestKey returns the API key a request was authenticated with, if any.
+func requestKey(r *http.Request) (apiKey, bool) {
+	k, ok := r.Context().Value(apiKeyContextKey{}).(apiKey)
+	return k, ok
+}
+
+// authenticate returns the API key a request was made with, looking it up
+// itself when rateLimit is disabled and has not done so.
+func authenticate(r *http.Request) (apiKey, bool) {
+	if key, ok := requestKey(r); ok {
+		return key, true
+	}
+	return lookupKey(presentedKey(r))
+}
+
+func withKey(parent context.Context, key apiKey) context.Context {
+	return context.WithValue(parent, apiKeyContextKey{}, key)
+}
+
+// presentedKey reads a key from the X-API-Key header, a bearer token, or
+// the api_key query parameter for clients such as EventSource that cannot
+// set headers.
+func presentedKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+func clientIP(r *http.Request) string {
+	if conf.RateLimit.TrustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			return strings.TrimSpace(strings.Split(xff, ",")[0])
+		}
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// takeToken runs tokenBucket for bucket with a limit in requests per
+// minute. It returns the tokens left and, when denied, how long to wait.
+func takeToken(bucket string, limit int) (allowed bool, remaining int64, reset, retry time.Duration, err error) {
+	res, err := tokenBucket.Run(ctx, rdb, []string{bucket},
+		float64(limit)/60, limit, time.Now().UnixNano()/int64(time.Millisecond)).Result()
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+
+	values := res.([]interface{})
+	allowed = values[0].(int64) == 1
+	remaining = values[1].(int64)
+	reset = time.Duration(values[2].(int64)) * time.Millisecond
+	retry = time.Duration(values[3].(int64)) * time.Millisecond
+	return allowed, remaining, reset, retry, nil
+}
+
+// countUsage adds a request to the key's usage for today.
+func countUsage(id int, limited bool) {
+	key := usageKey(id, time.Now().UTC().Format("2006-01-02"))
+
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, "requests", 1)
+		if limited {
+			pipe.HIncrBy(ctx, key, "limited", 1)
+		}
+		pipe.Expire(ctx, key, usageRetention)
+		return nil
+	})
+	if err != nil {
+		log.Printf("error: could not count usage of key %d: %v\n", id, err)
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
+// rateLimit authenticates optional API keys and applies a token bucket per
+// key, or per client IP for anonymous requests. If Redis is unavailable
+// requests are let through rather than failing the whole API.
+func rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultAnonymousRate
+		if conf.RateLimit.Anonymous > 0 {
+			limit = conf.RateLimit.Anonymous
+		}
+		bucket := "gt.ratelimit.ip." + clientIP(r)
+
+		key, hasKey := apiKey{}, false
+		if presented := presentedKey(r); presented != "" {
+			if key, hasKey = lookupKey(presented); !hasKey {
+				writeError(w, http.StatusUnauthorized, "unauthorized", "invalid or revoked API key")
+				return
+			}
+
+			limit = defaultKeyRate
+			if key.Rate > 0 {
+				limit = key.Rate
+			} else if conf.RateLimit.Key > 0 {
+				limit = conf.RateLimit.Key
+			}
+			bucket = "gt.ratelimit.key." + strconv.Itoa(key.ID)
+			r = r.WithContext(withKey(r.Context(), key))
+		}
+
+		allowed, remaining, reset, retry, err := takeToken(bucket, limit)
+		if err != nil {
+			log.Printf("error: could not apply rate limit: %v\n", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if hasKey {
+			countUsage(key.ID, !allowed)
+		}
+
+		h := w.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		h.Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		h.Set("X-RateLimit-Reset", seconds(reset))
+
+		if !allowed {
+			h.Set("Retry-After", seconds(retry))
+			writeError(w, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded, retry in "+seconds(retry)+" seconds")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/testenv"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRateLimit(t *testing.T) {
+	rdb = testenv.Redis(t)
+	conf.RateLimit = rateLimitConfig{Anonymous: 3}
+	defer func() { conf.RateLimit = rateLimitConfig{} }()
+
+	handler := rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// addresses of their own, so that buckets left by earlier runs are not
+	// reused; three requests a minute refill a token every 20 seconds
+	n := time.Now().UnixNano()
+	first := fmt.Sprintf("10.%d.%d.%d", n>>16&0xff, n>>8&0xff, n&0xff)
+	second := fmt.Sprintf("10.%d.%d.%d", n>>16&0xff, n>>8&0xff, (n+1)&0xff)
+	defer rdb.Del(ctx, "gt.ratelimit.ip."+first, "gt.ratelimit.ip."+second)
+
+	tests := []struct {
+		name          string
+		ip            string
+		wantStatus    int
+		wantRemaining string
+		wantReset     string
+		wantRetry     string
+	}{
+		{"first request", first, http.StatusOK, "2", "20", ""},
+		{"second request", first, http.StatusOK, "1", "40", ""},
+		{"last token", first, http.StatusOK, "0", "60", ""},
+		{"empty", first, http.StatusTooManyRequests, "0", "60", "20"},
+		{"still empty", first, http.StatusTooManyRequests, "0", "60", "20"},
+		{"another client", second, http.StatusOK, "2", "20", ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/gt-jpj/cases", nil)
+		r.RemoteAddr = tt.ip + ":1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		h := w.Header()
+		if w.Code != tt.wantStatus || h.Get("X-RateLimit-Limit") != "3" || h.Get("X-RateLimit-Remaining") != tt.wantRemaining ||
+			h.Get("X-RateLimit-Reset") != tt.wantReset || h.Get("Retry-After") != tt.wantRetry {
+			t.Errorf("%s: got %d, remaining %q, reset %q, retry %q; want %d, %q, %q, %q", tt.name, w.Code,
+				h.Get("X-RateLimit-Remaining"), h.Get("X-RateLimit-Reset"), h.Get("Retry-After"),
+				tt.wantStatus, tt.wantRemaining, tt.wantReset, tt.wantRetry)
+		}
+	}
+}
+
+func TestRateLimitWithoutRedis(t *testing.T) {
+	// nothing listens on the discard port
+	rdb = redis.NewClient(&redis.Options{Addr: "127.0.0.1:9", MaxRetries: -1})
+	defer rdb.Close()
+
+	served := false
+	handler := rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served = true }))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/gt-jpj/cases", nil))
+	if !served || w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("got %d, served %v, limit %q; want the request let through without limit headers",
+			w.Code, served, w.Header().Get("X-RateLimit-Limit"))
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Defaults used when Server leaves a setting unset.
+const (
+	defaultAddress           = ":3000"
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 15 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultShutdownTimeout   = 30 * time.Second
+)
+
+// serverConfig configures the HTTP listener. Timeouts are in seconds.
+//
+// WriteTimeout is off by default because it also bounds /gt-jpj/events
+// streams, which stay open for as long as the client listens.
+type serverConfig struct {
+	Address         string
+	Socket          string // listen on this Unix socket instead of Address
+	TLSCert         string
+	TLSKey          string
+	ReadTimeout     int
+	WriteTimeout    int
+	IdleTimeout     int
+	ShutdownTimeout int // how long to wait for requests to drain
+}
+
+// shuttingDown is closed when the server starts draining, so that
+// long-lived streams end instead of holding the shutdown up.
+var (
+	shuttingDown = make(chan struct{})
+	shutdownOnce sync.Once
+)
+
+func beginShutdown() {
+	shutdownOnce.Do(func() { close(shuttingDown) })
+}
+
+// durationOr converts n seconds, using fallback when n is unset.
+func durationOr(n int, fallback time.Duration) time.Duration {
+	if n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return fallback
+}
+
+func newServer(handler http.Handler) *http.Server {
+	c := conf.Server
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
+		ReadTimeout:       durationOr(c.ReadTimeout, defaultReadTimeout),
+		WriteTimeout:      durationOr(c.WriteTimeout, 0),
+		IdleTimeout:       durationOr(c.IdleTimeout, defaultIdleTimeout),
+	}
+}
+
+func shutdownTimeout() time.Duration {
+	return durationOr(conf.Server.ShutdownTimeout, defaultShutdownTimeout)
+}
+
+// listen opens the configured Unix socket or TCP address.
+func listen() (net.Listener, error) {
+	c := conf.Server
+
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return nil, errors.New("Server.TLSCert and Server.TLSKey must be set together")
+	}
+
+	if c.Socket != "" {
+		// a socket left behind by an unclean exit would make Listen fail
+		if err := os.Remove(c.Socket); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.Listen("unix", c.Socket)
+	}
+
+	address := defaultAddress
+	if c.Address != "" {
+		address = c.Address
+	}
+	return net.Listen("tcp", address)
+}
+
+// serve runs srv on lis until it is shut down, in which case it returns
+// nil rather than http.ErrServerClosed.
+func serve(srv *http.Server, lis net.Listener) error {
+	var err error
+	if conf.Server.TLSCert != "" {
+		err = srv.ServeTLS(lis, conf.Server.TLSCert, conf.Server.TLSKey)
+	} else {
+		err = srv.Serve(lis)
+	}
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/notify"
+	"github.com/go-chi/chi"
+	"github.com/lib/pq"
+)
+
+// Alert types a subscription can receive. A daily alert is sent when GT
+// publishes a new day, a revision alert when it corrects one, a rule
+// alert when one of the scrapers' alert rules fires, and a weekly alert
+// with the digest of each week.
+const (
+	AlertDaily    = "daily"
+	AlertRevision = "revision"
+	AlertRule     = notify.AlertRule
+	AlertWeekly   = notify.AlertWeekly
+)
+
+var (
+	subscriptionDatasets   = []string{"cases", "surveys"}
+	subscriptionAlertTypes = []string{AlertDaily, AlertRevision, AlertRule, AlertWeekly}
+
+	// defaultAlertTypes are delivered when a subscription names none. The
+	// weekly digest is only sent to those who ask for it.
+	defaultAlertTypes = []string{AlertDaily, AlertRevision, AlertRule}
+)
+
+// Subscription is a row of the webhook_subscriptions table. Each one
+// belongs to the API key that registered it.
+type Subscription struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	URL        string    `json:"url"`
+	Datasets   []string  `json:"datasets"`
+	AlertTypes []string  `json:"alert_types"`
+	Locale     string    `json:"locale"`
+	Paused     bool      `json:"paused"`
+	CreatedAt  time.Time `json:"created_at"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// SubscriptionRequest registers or updates a subscription. On update only
+// the fields that are set are changed.
+type SubscriptionRequest struct {
+	Name       *string  `json:"name"`
+	Type       *string  `json:"type"`
+	URL        *string  `json:"url"`
+	Datasets   []string `json:"datasets"`
+	AlertTypes []string `json:"alert_types"`
+	Locale     *string  `json:"locale"`
+	Paused     *bool    `json:"paused"`
+}
+
+// requireKey only lets through requests made with an API key.
+func requireKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := authenticate(r)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "an API key is required")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(withKey(r.Context(), key)))
+	})
+}
+
+func subscriptionRoutes(r chi.Router) {
+	r.Use(requireKey)
+
+	r.Get("/", listSubscriptions)
+	r.Post("/", createSubscription)
+	r.Get("/{id}", getSubscription)
+	r.Patch("/{id}", updateSubscription)
+	r.Delete("/{id}", deleteSubscription)
+}
+
+// checkOptions validates values against allowed and drops duplicates.
+func checkOptions(name string, values, allowed []string) ([]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	list := make([]string, 0, len(values))
+	for _, v := range values {
+		ok := false
+		for _, a := range allowed {
+			ok = ok || v == a
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s must be some of %s", name, strings.Join(allowed, ", "))
+		}
+		if !seen[v] {
+			seen[v] = true
+			list = append(list, v)
+		}
+	}
+	return list, nil
+}
+
+// pingWebhook posts a confirmation to a newly registered destination, so
+// that a typo or a deleted webhook is caught at registration. The URL is
+// checked against the destination type first, as the backend sends the
+// ping itself.
+func pingWebhook(ctx context.Context, kind, webhook string, datasets []string) error {
+	n, err := notify.New(kind, webhook)
+	if err != nil {
+		return err
+	}
+
+	return n.Notify(ctx, notify.Message{
+		Username: "GT Stamps Health Services",
+		Text:     "This channel is now subscribed to GT JPJ updates for " + strings.Join(datasets, " and ") + ".",
+	})
+}
+
+const subscriptionColumns = `id, name, type, url, datasets, alert_types, locale, paused, created_at, verified_at`
+
+func scanSubscription(row interface{ Scan(...interface{}) error }) (Subscription, error) {
+	var s Subscription
+	err := row.Scan(&s.ID, &s.Name, &s.Type, &s.URL, pq.Array(&s.Datasets), pq.Array(&s.AlertTypes), &s.Locale, &s.Paused, &s.CreatedAt, &s.VerifiedAt)
+	return s, err
+}
+
+// ownedSubscription loads a subscription visible to the request's key.
+// Admin keys can see every subscription.
+func ownedSubscription(w http.ResponseWriter, r *http.Request) (Subscription, bool) {
+	key, _ := requestKey(r)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not_found", "no subscription with id "+chi.URLParam(r, "id"))
+		return Subscription{}, false
+	}
+
+	s, err := scanSubscription(db.QueryRow(`SELECT `+subscriptionColumns+` FROM webhook_subscriptions
+		WHERE id = $1 AND ($2 OR key_id = $3)`, id, key.Admin, key.ID))
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "not_found", "no subscription with id "+strconv.Itoa(id))
+		return Subscription{}, false
+	} else if err != nil {
+		log.Printf("error: could not load subscription %d: %v\n", id, err)
+		writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+		return Subscription{}, false
+	}
+
+	return s, true
+}
+
+func decodeSubscriptionRequest(w http.ResponseWriter, r *http.Request) (SubscriptionRequest, bool) {
+	var req SubscriptionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "body must be a JSON object")
+		return req, false
+	}
+
+	var err error
+	if req.Datasets, err = checkOptions("datasets", req.Datasets, subscriptionDatasets); err == nil {
+		req.AlertTypes, err = checkOptions("alert_types", req.AlertTypes, subscriptionAlertTypes)
+	}
+	if err == nil && req.Locale != nil {
+		err = notify.CheckLocale(*req.Locale)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return req, false
+	}
+
+	return req, true
+}
+
+func listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	key, _ := requestKey(r)
+
+	rows, err := db.Query(`SELECT `+subscriptionColumns+` FROM webhook_subscriptions
+		WHERE $1 OR key_id = $2 ORDER BY id`, key.Admin, key.ID)
+	if err != nil {
+		log.Printf("error: could not list subscriptions: %v\n", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+		return
+	}
+
+	defer rows.Close()
+
+	list := make([]Subscription, 0)
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			log.Printf("error: could not list subscriptions: %v\n", err)
+			writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+			return
+		}
+		list = append(list, s)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("error: could not list subscriptions: %v\n", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+		return
+	}
+
+	writeData(w, http.StatusOK, list, ListMeta{Count: len(list)})
+}
+
+func getSubscription(w http.ResponseWriter, r *http.Request) {
+	if s, ok := ownedSubscription(w, r); ok {
+		writeData(w, http.StatusOK, s, nil)
+	}
+}
+
+func createSubscription(w http.ResponseWriter, r *http.Request) {
+	key, _ := requestKey(r)
+
+	req, ok := decodeSubscriptionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if req.URL == nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "url is required")
+		return
+	}
+	kind := notify.TypeDiscord
+	if req.Type != nil {
+		kind = *req.Type
+	}
+	if err := notify.CheckURL(kind, *req.URL); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	name := key.Name
+	if req.Name != nil {
+		name = *req.Name
+	}
+	if req.Datasets == nil {
+		req.Datasets = subscriptionDatasets
+	}
+	if req.AlertTypes == nil {
+		req.AlertTypes = defaultAlertTypes
+	}
+	locale := notify.DefaultLocale
+	if req.Locale != nil {
+		locale = *req.Locale
+	}
+
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM webhook_subscriptions WHERE url = $1)`, *req.URL).Scan(&exists); err != nil {
+		log.Printf("error: could not create subscription: %v\n", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+		return
+	} else if exists {
+		writeError(w, http.StatusConflict, "conflict", "this webhook is already subscribed")
+		return
+	}
+
+	if err := pingWebhook(r.Context(), kind, *req.URL, req.Datasets); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "verification_failed", "could not deliver the verification ping: "+err.Error())
+		return
+	}
+
+	s, err := scanSubscription(db.QueryRow(`INSERT INTO webhook_subscriptions (key_id, name, type, url, datasets, alert_types, locale, paused, verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+		RETURNING `+subscriptionColumns,
+		key.ID, name, kind, *req.URL, pq.Array(req.Datasets), pq.Array(req.AlertTypes), locale, req.Paused != nil && *req.Paused))
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		writeError(w, http.StatusConflict, "conflict", "this webhook is already subscribed")
+		return
+	}
+	if err != nil {
+		log.Printf("error: could not create subscription: %v\n", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+		return
+	}
+
+	writeData(w, http.StatusCreated, s, nil)
+}
+
+// updateSubscription renames, pauses, resumes or changes the options of a
+// subscription. The URL and type cannot be changed; register a new one
+// instead.
+func updateSubscription(w http.ResponseWriter, r *http.Request) {
+	s, ok := ownedSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := decodeSubscriptionRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.URL != nil || req.Type != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "url and type cannot be changed, register a new subscription instead")
+		return
+	}
+
+	if req.Name != nil {
+		s.Name = *req.Name
+	}
+	if req.Paused != nil {
+		s.Paused = *req.Paused
+	}
+	if req.Datasets != nil {
+		s.Datasets = req.Datasets
+	}
+	if req.AlertTypes != nil {
+		s.AlertTypes = req.AlertTypes
+	}
+	if req.Locale != nil {
+		s.Locale = *req.Locale
+	}
+
+	s, err := scanSubscription(db.QueryRow(`UPDATE webhook_subscriptions
+		SET name = $2, datasets = $3, alert_types = $4, locale = $5, paused = $6
+		WHERE id = $1
+		RETURNING `+subscriptionColumns,
+		s.ID, s.Name, pq.Array(s.Datasets), pq.Array(s.AlertTypes), s.Locale, s.Paused))
+	if err != nil {
+		log.Printf("error: could not update subscription %d: %v\n", s.ID, err)
+		writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+		return
+	}
+
+	writeData(w, http.StatusOK, s, nil)
+}
+
+func deleteSubscription(w http.ResponseWriter, r *http.Request) {
+	s, ok := ownedSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := db.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, s.ID); err != nil {
+		log.Printf("error: could not delete subscription %d: %v\n", s.ID, err)
+		writeError(w, http.StatusInternalServerError, "internal", "Internal Server Error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runSubscriptionCommand implements `backend subscription add`, used to
+// move the webhooks once listed in the scrapers' config into the store.
+func runSubscriptionCommand(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: backend subscription add [-name NAME] [-type discord] [-datasets cases,surveys] [-alerts daily,revision,rule] [-locale en] URL...")
+		os.Exit(2)
+	}
+
+	if len(args) == 0 || args[0] != "add" {
+		usage()
+	}
+
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	name := fs.String("name", "config", "name of the subscriptions")
+	kind := fs.String("type", notify.TypeDiscord, "destination type: "+strings.Join(notify.Types, ", "))
+	datasets := fs.String("datasets", strings.Join(subscriptionDatasets, ","), "datasets to deliver")
+	alerts := fs.String("alerts", strings.Join(defaultAlertTypes, ","), "alert types to deliver")
+	locale := fs.String("locale", notify.DefaultLocale, "language of the posts: "+strings.Join(notify.Locales, ", "))
+	fs.Parse(args[1:])
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	datasetList, err := checkOptions("datasets", strings.Split(*datasets, ","), subscriptionDatasets)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	alertList, err := checkOptions("alerts", strings.Split(*alerts, ","), subscriptionAlertTypes)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	if err := notify.CheckLocale(*locale); err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	for _, webhook := range fs.Args() {
+		if err := pingWebhook(ctx, *kind, webhook, datasetList); err != nil {
+			log.Printf("error: %s: %v\n", webhook, err)
+			continue
+		}
+
+		var id int
+		err := db.QueryRow(`INSERT INTO webhook_subscriptions (name, type, url, datasets, alert_types, locale)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (url) DO NOTHING
+			RETURNING id`, *name, *kind, webhook, pq.Array(datasetList), pq.Array(alertList), *locale).Scan(&id)
+		if err == sql.ErrNoRows {
+			fmt.Printf("already subscribed: %s\n", webhook)
+			continue
+		} else if err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+		fmt.Printf("added subscription %d\n", id)
+	}
+}
@@ -0,0 +1,79 @@
+// Package testenv connects tests to the Postgres and Redis servers named
+// by GT_TEST_DATABASE and GT_TEST_REDIS. Tests that need one are skipped
+// when it is not set, except in CI, where they fail so that a missing
+// server is never mistaken for passing tests.
+package testenv
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	_ "github.com/lib/pq"
+)
+
+// require returns the value of the environment variable name, skipping
+// or failing t if it is not set.
+func require(t testing.TB, name string) string {
+	v := os.Getenv(name)
+	if v == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("%s must be set in CI", name)
+		}
+		t.Skipf("%s is not set", name)
+	}
+	return v
+}
+
+// Database connects to the Postgres database at GT_TEST_DATABASE, a
+// connection string, with a schema of its own that is dropped after the
+// test, so that tests of several packages can run at once.
+func Database(t testing.TB) *sql.DB {
+	dsn := require(t, "GT_TEST_DATABASE")
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("gt_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		admin.Close()
+	})
+
+	switch {
+	case strings.Contains(dsn, "?"):
+		dsn += "&search_path=" + schema
+	case strings.Contains(dsn, "://"):
+		dsn += "?search_path=" + schema
+	default:
+		dsn += " search_path=" + schema
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Redis connects to the Redis server at GT_TEST_REDIS, such as
+// localhost:6379. Tests should use keys of their own.
+func Redis(t testing.TB) *redis.Client {
+	addr := require(t, "GT_TEST_REDIS")
+
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// Corrected marks days entered or corrected through the admin API rather
+// than taken from GT as published.
+type CaseDay struct {
+	ID        int    `json:"id"`
+	Date      string `json:"date"`
+	Reported  int    `json:"reported"`
+	Total     int    `json:"total"`
+	Corrected bool   `json:"corrected"`
+}
+
+type SurveyDay struct {
+	ID           int    `json:"id"`
+	Date         string `json:"date"`
+	Positive     int    `json:"positive"`
+	Administered int    `json:"administered"`
+	Corrected    bool   `json:"corrected"`
+}
+
+// Envelope is the body of every /v2 response. Exactly one of Data and Error
+// is set.
+type Envelope struct {
+	Data  interface{} `json:"data"`
+	Meta  interface{} `json:"meta"`
+	Error *APIError   `json:"error"`
+}
+
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ListMeta struct {
+	Count int `json:"count"`
+}
+
+type IndexData struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func v2Routes(r chi.Router) {
+	r.NotFound(v2NotFound)
+	r.MethodNotAllowed(v2MethodNotAllowed)
+
+	r.Get("/", v2Index)
+	r.Group(func(r chi.Router) {
+		r.Use(cacheDataset)
+
+		r.Get("/cases", v2ListCases)
+		r.Get("/cases/{date}", v2GetCase)
+		r.Get("/testing", v2ListSurveys)
+		r.Get("/testing/{date}", v2GetSurvey)
+	})
+}
+
+func writeData(w http.ResponseWriter, status int, data, meta interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Envelope{Data: data, Meta: meta})
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Envelope{Error: &APIError{Code: code, Message: message}})
+}
+
+func v2NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, "not_found", "no route for "+r.URL.Path)
+}
+
+func v2MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not allowed on "+r.URL.Path)
+}
+
+func v2Index(w http.ResponseWriter, r *http.Request) {
+	writeData(w, http.StatusOK, IndexData{
+		Name:    "Georgia Institute of Technology JPJ Tracking API - Not affiliated with Georgia Tech",
+		Version: "v2",
+	}, nil)
+}
+
+// validDate reports whether date is a YYYY-MM-DD path parameter.
+func validDate(date string) bool {
+	_, err := time.Parse("2006-01-02", date)
+	return err == nil
+}
+
+// writeBody writes a pre-encoded envelope from the dataset.
+func writeBody(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(body)
+}
+
+func v2ListCases(w http.ResponseWriter, r *http.Request) {
+	writeBody(w, requestDataset(r).v2Cases)
+}
+
+func v2GetCase(w http.ResponseWriter, r *http.Request) {
+	date := chi.URLParam(r, "date")
+	if !validDate(date) {
+		writeError(w, http.StatusBadRequest, "bad_request", "date must be formatted as YYYY-MM-DD")
+		return
+	}
+
+	body, ok := requestDataset(r).v2CaseByDate[date]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "no cases reported for "+date)
+		return
+	}
+
+	writeBody(w, body)
+}
+
+func v2ListSurveys(w http.ResponseWriter, r *http.Request) {
+	writeBody(w, requestDataset(r).v2Surveys)
+}
+
+func v2GetSurvey(w http.ResponseWriter, r *http.Request) {
+	date := chi.URLParam(r, "date")
+	if !validDate(date) {
+		writeError(w, http.StatusBadRequest, "bad_request", "date must be formatted as YYYY-MM-DD")
+		return
+	}
+
+	body, ok := requestDataset(r).v2SurveyByDate[date]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "no surveillance results for "+date)
+		return
+	}
+
+	writeBody(w, body)
+}
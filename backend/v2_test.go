@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestV2Errors(t *testing.T) {
+	r := chi.NewRouter()
+	r.Route("/gt-jpj/v2", v2Routes)
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"GET", "/gt-jpj/v2/nothing", http.StatusNotFound, "not_found"},
+		{"POST", "/gt-jpj/v2/cases", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"GET", "/gt-jpj/v2/cases/09-21-2020", http.StatusBadRequest, "bad_request"},
+		{"GET", "/gt-jpj/v2/testing/September%2021", http.StatusBadRequest, "bad_request"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		var body Envelope
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Errorf("%s %s: %v", tt.method, tt.path, err)
+			continue
+		}
+		if w.Code != tt.wantStatus || body.Error == nil || body.Error.Code != tt.wantCode || body.Data != nil {
+			t.Errorf("%s %s: got %d %+v, want %d with error %s", tt.method, tt.path, w.Code, body, tt.wantStatus, tt.wantCode)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("%s %s: Content-Type %q", tt.method, tt.path, ct)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/gt-jpj/v2/", nil))
+	var index struct{ Data IndexData }
+	if err := json.NewDecoder(w.Body).Decode(&index); err != nil || w.Code != http.StatusOK || index.Data.Version != "v2" {
+		t.Errorf("index: got %d %+v, %v", w.Code, index, err)
+	}
+}
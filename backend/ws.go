@@ -0,0 +1,313 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/events"
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
+	"github.com/gorilla/websocket"
+	"github.com/lib/pq"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+
+	// wsSendBuffer is how many messages may queue for a client before it
+	// is considered too slow and disconnected.
+	wsSendBuffer = 32
+
+	// wsRecentEvents bounds the snapshot sent for event-only channels.
+	wsRecentEvents = 50
+)
+
+// channelKinds maps each WebSocket channel to the event kind it carries.
+var channelKinds = map[string]string{
+	"cases":     events.KindCase,
+	"testing":   events.KindSurvey,
+	"revisions": events.KindRevision,
+	"alerts":    events.KindAlert,
+}
+
+var upgrader = websocket.Upgrader{
+	// the API is public and read-only, so any page may open a feed
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsRequest is sent by clients to change their subscriptions.
+type wsRequest struct {
+	Type    string `json:"type"` // "subscribe" or "unsubscribe"
+	Channel string `json:"channel"`
+}
+
+// wsMessage is sent to clients. A "snapshot" carries the current state of a
+// channel, an "update" carries a single Event.
+type wsMessage struct {
+	Type    string      `json:"type"`
+	Channel string      `json:"channel,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   *APIError   `json:"error,omitempty"`
+}
+
+type wsClient struct {
+	conn *websocket.Conn
+	send chan wsMessage
+
+	mu       sync.Mutex
+	channels map[string]bool
+	pending  map[string][]events.Event // updates held until the snapshot of a channel is queued
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// recentEvents returns the latest events of kind, oldest first.
+func recentEvents(kind string, limit int) ([]events.Event, error) {
+	defer observeQuery("recent_events", time.Now())
+
+	rows, err := db.Query(`SELECT id, kind, date, payload, created_at FROM (
+		SELECT * FROM events WHERE kind = $1 ORDER BY id DESC LIMIT $2
+	) recent ORDER BY id`, kind, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEvents(rows)
+}
+
+func scanEvents(rows *sql.Rows) ([]events.Event, error) {
+	defer rows.Close()
+
+	list := make([]events.Event, 0)
+	for rows.Next() {
+		e := events.Event{}
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Date, &e.Data, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Date = gtdate.ISO(e.Date)
+		list = append(list, e)
+	}
+
+	return list, rows.Err()
+}
+
+// recentEventsOn returns the latest events of kind stored for any of
+// dates, oldest first.
+func recentEventsOn(kind string, dates []string, limit int) ([]events.Event, error) {
+	defer observeQuery("recent_events_on", time.Now())
+
+	rows, err := db.Query(`SELECT id, kind, date, payload, created_at FROM (
+		SELECT * FROM events WHERE kind = $1 AND date = ANY($2) ORDER BY id DESC LIMIT $3
+	) recent ORDER BY id`, kind, pq.Array(dates), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEvents(rows)
+}
+
+func channelSnapshot(channel string) (interface{}, error) {
+	switch channel {
+	case "cases":
+		return currentDataset().cases, nil
+	case "testing":
+		return currentDataset().surveys, nil
+	default:
+		return recentEvents(channelKinds[channel], wsRecentEvents)
+	}
+}
+
+// serveWebSocket serves /gt-jpj/ws, a live feed where clients pick the
+// channels they want. Each subscription starts with a snapshot and is
+// followed by an update per stored event.
+func serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already written an error response
+		return
+	}
+
+	c := &wsClient{
+		conn:     conn,
+		send:     make(chan wsMessage, wsSendBuffer),
+		channels: make(map[string]bool),
+		pending:  make(map[string][]events.Event),
+		done:     make(chan struct{}),
+	}
+
+	live := hub.subscribe()
+	defer hub.unsubscribe(live)
+
+	go c.writePump()
+	go c.readPump()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-shuttingDown:
+			c.close(websocket.CloseServiceRestart, "server shutting down")
+			return
+		case e, ok := <-live:
+			if !ok {
+				c.close(websocket.CloseTryAgainLater, "too slow")
+				return
+			}
+			for channel, kind := range channelKinds {
+				if kind == e.Kind {
+					c.update(channel, e)
+				}
+			}
+		}
+	}
+}
+
+// update queues e for channel if the client is subscribed to it, or holds
+// it back while the snapshot of channel is loading.
+func (c *wsClient) update(channel string, e events.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if held, ok := c.pending[channel]; ok {
+		if len(held) >= wsSendBuffer {
+			c.close(websocket.CloseTryAgainLater, "too slow")
+			return
+		}
+		c.pending[channel] = append(held, e)
+	} else if c.channels[channel] {
+		c.enqueue(wsMessage{Type: "update", Channel: channel, Data: e})
+	}
+}
+
+// subscribe subscribes the client to channel and queues its snapshot.
+// The snapshot is loaded without the lock, which the broadcast loop needs
+// for every event.
+func (c *wsClient) subscribe(channel string) {
+	c.hold(channel)
+	data, err := channelSnapshot(channel)
+	c.release(channel, data, err)
+}
+
+// hold subscribes the client to channel, holding back its updates until
+// release.
+func (c *wsClient) hold(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.channels[channel] = true
+	c.pending[channel] = []events.Event{}
+}
+
+// release queues the snapshot of channel followed by the updates held
+// back while it loaded, leaving out events the snapshot already holds. If
+// the snapshot could not be loaded, the client is unsubscribed instead.
+func (c *wsClient) release(channel string, snapshot interface{}, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	held := c.pending[channel]
+	delete(c.pending, channel)
+	if err != nil {
+		log.Printf("error: could not load snapshot of %s: %v\n", channel, err)
+		delete(c.channels, channel)
+		c.enqueue(wsMessage{Type: "error", Channel: channel, Error: &APIError{Code: "internal", Message: "Internal Server Error"}})
+		return
+	}
+
+	var last int64
+	if list, ok := snapshot.([]events.Event); ok && len(list) > 0 {
+		last = list[len(list)-1].ID
+	}
+	c.enqueue(wsMessage{Type: "snapshot", Channel: channel, Data: snapshot})
+	for _, e := range held {
+		if e.ID > last {
+			c.enqueue(wsMessage{Type: "update", Channel: channel, Data: e})
+		}
+	}
+}
+
+// enqueue queues msg without blocking. A client that cannot keep up is
+// disconnected rather than allowed to hold back the broker.
+func (c *wsClient) enqueue(msg wsMessage) {
+	select {
+	case c.send <- msg:
+	default:
+		c.close(websocket.CloseTryAgainLater, "too slow")
+	}
+}
+
+func (c *wsClient) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+		c.conn.Close()
+	})
+}
+
+func (c *wsClient) readPump() {
+	defer c.close(websocket.CloseNormalClosure, "")
+
+	c.conn.SetReadLimit(512)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var req wsRequest
+		if err := c.conn.ReadJSON(&req); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				c.enqueue(wsMessage{Type: "error", Error: &APIError{Code: "bad_request", Message: "messages must be JSON"}})
+				continue
+			}
+			return
+		}
+
+		if _, ok := channelKinds[req.Channel]; !ok {
+			c.enqueue(wsMessage{Type: "error", Error: &APIError{Code: "not_found", Message: "no channel named " + req.Channel}})
+			continue
+		}
+
+		switch req.Type {
+		case "subscribe":
+			c.subscribe(req.Channel)
+		case "unsubscribe":
+			c.mu.Lock()
+			delete(c.channels, req.Channel)
+			delete(c.pending, req.Channel)
+			c.mu.Unlock()
+		default:
+			c.enqueue(wsMessage{Type: "error", Error: &APIError{Code: "bad_request", Message: "type must be subscribe or unsubscribe"}})
+		}
+	}
+}
+
+func (c *wsClient) writePump() {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
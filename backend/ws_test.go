@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/events"
+)
+
+func TestSubscribeHoldsUpdates(t *testing.T) {
+	event := func(id int64) events.Event { return events.Event{ID: id, Kind: events.KindRevision} }
+
+	tests := []struct {
+		name     string
+		snapshot []events.Event
+		err      error
+		held     []int64 // published while the snapshot loads
+		want     []string
+	}{
+		{"nothing published", []events.Event{event(1), event(2)}, nil, nil, []string{"snapshot"}},
+		{"published after the snapshot query", []events.Event{event(1)}, nil, []int64{2, 3}, []string{"snapshot", "update 2", "update 3"}},
+		{"published before the snapshot query", []events.Event{event(1), event(2)}, nil, []int64{2, 3}, []string{"snapshot", "update 3"}},
+		{"empty snapshot", []events.Event{}, nil, []int64{1}, []string{"snapshot", "update 1"}},
+		{"snapshot failed", nil, errors.New("down"), []int64{1}, []string{"error"}},
+	}
+
+	for _, tt := range tests {
+		c := &wsClient{
+			send:     make(chan wsMessage, wsSendBuffer),
+			channels: make(map[string]bool),
+			pending:  make(map[string][]events.Event),
+		}
+
+		c.hold("revisions")
+		for _, id := range tt.held {
+			c.update("revisions", event(id))
+			c.update("alerts", event(id)) // not subscribed
+		}
+		if len(c.send) != 0 {
+			t.Errorf("%s: %d messages queued before the snapshot", tt.name, len(c.send))
+		}
+		c.release("revisions", tt.snapshot, tt.err)
+
+		got := []string{}
+		for len(c.send) > 0 {
+			msg := <-c.send
+			if e, ok := msg.Data.(events.Event); ok {
+				got = append(got, fmt.Sprintf("%s %d", msg.Type, e.ID))
+			} else {
+				got = append(got, msg.Type)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: queued %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: queued %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+
+		// once released, updates go straight out unless the snapshot failed
+		c.update("revisions", event(9))
+		if subscribed := len(c.send) == 1; subscribed != (tt.err == nil) {
+			t.Errorf("%s: update after release queued %v, want %v", tt.name, subscribed, tt.err == nil)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/client"
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
+	"github.com/adityaxdiwakar/gt-cases/backend/notify"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	chartDays   = 30
+	chartWidth  = 720
+	chartHeight = 360
+)
+
+// plot area margins, leaving room for the title and axis labels
+const (
+	marginLeft   = 56
+	marginRight  = 16
+	marginTop    = 32
+	marginBottom = 28
+)
+
+var (
+	chartBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	chartGrid       = color.RGBA{0xe5, 0xe5, 0xe5, 0xff}
+	chartText       = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	chartBar        = color.RGBA{0xb3, 0xa3, 0x69, 0xff} // GT gold, as the embeds
+	chartLine       = color.RGBA{0x00, 0x30, 0x57, 0xff} // GT navy
+)
+
+// series returns the reported cases of the last n days, oldest first.
+func series(cases []client.CaseDay, n int) []int {
+	if len(cases) > n {
+		cases = cases[len(cases)-n:]
+	}
+	list := make([]int, len(cases))
+	for i, c := range cases {
+		list[i] = c.Reported
+	}
+	return list
+}
+
+// movingAverages returns the 7-day moving average of reported cases on each
+// of the last n days, over the days before them where there are any.
+func movingAverages(cases []client.CaseDay, n int) []float64 {
+	first := len(cases) - n
+	if first < 0 {
+		first = 0
+	}
+	list := make([]float64, 0, len(cases)-first)
+	for i := first; i < len(cases); i++ {
+		start := i - 6
+		if start < 0 {
+			start = 0
+		}
+		list = append(list, averagePayload(cases[start:i+1]))
+	}
+	return list
+}
+
+// shortDate formats a date as published by GT as e.g. Sep 21, or leaves it
+// as is if it cannot be parsed.
+func shortDate(date string) string {
+	t, err := gtdate.Parse(date)
+	if err != nil {
+		return strings.TrimSpace(date)
+	}
+	return t.Format("Jan 2")
+}
+
+// niceCeiling rounds n up to 1, 2 or 5 times a power of ten, so the grid
+// falls on round numbers.
+func niceCeiling(n float64) float64 {
+	if n <= 4 {
+		return 4
+	}
+	step := 1.0
+	for step*10 <= n {
+		step *= 10
+	}
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*step >= n {
+			return m * step
+		}
+	}
+	return 10 * step
+}
+
+// chartFile renders the chart of cases to upload with a post, or returns
+// nil if it cannot, in which case templates show the series as text.
+func chartFile(cases []client.CaseDay) *notify.File {
+	chart, err := renderChart(cases)
+	if err != nil {
+		log.Printf("error: could not render chart: %v\n", err)
+		return nil
+	}
+	return &notify.File{Name: "chart.png", ContentType: "image/png", Data: chart}
+}
+
+// revisedChart renders the chart again after GT corrected the latest day
+// to figures. The backend may not have the correction yet, so it is made
+// here too.
+func revisedChart(figures caseFigures) *notify.File {
+	cases, err := api.Cases(ctx)
+	if err != nil {
+		log.Printf("error: could not load cases to chart: %v\n", err)
+		return nil
+	}
+	if len(cases) > 0 {
+		cases[len(cases)-1].Reported, cases[len(cases)-1].Total = figures.Reported, figures.Total
+	}
+	return chartFile(cases)
+}
+
+// renderChart draws the reported cases of the last 30 days as bars with
+// their 7-day moving average as a line, and encodes it as a PNG.
+func renderChart(cases []client.CaseDay) ([]byte, error) {
+	if len(cases) < 2 {
+		return nil, errors.New("not enough days to chart")
+	}
+	reported := series(cases, chartDays)
+	averages := movingAverages(cases, chartDays)
+	days := cases[len(cases)-len(reported):]
+
+	top := 0.0
+	for i := range reported {
+		if v := float64(reported[i]); v > top {
+			top = v
+		}
+		if averages[i] > top {
+			top = averages[i]
+		}
+	}
+	top = niceCeiling(top)
+
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{chartBackground}, image.Point{}, draw.Src)
+
+	plot := image.Rect(marginLeft, marginTop, chartWidth-marginRight, chartHeight-marginBottom)
+	y := func(v float64) int {
+		return plot.Max.Y - int(v/top*float64(plot.Dy()))
+	}
+
+	// horizontal grid on whole numbers, labelled on the left
+	gridLines := 4
+	if int(top)%gridLines != 0 {
+		gridLines = 5
+	}
+	for i := 0; i <= gridLines; i++ {
+		v := top * float64(i) / float64(gridLines)
+		gy := y(v)
+		draw.Draw(img, image.Rect(plot.Min.X, gy, plot.Max.X, gy+1), &image.Uniform{chartGrid}, image.Point{}, draw.Src)
+		label := strconv.FormatFloat(v, 'f', -1, 64)
+		drawText(img, label, plot.Min.X-8-textWidth(label), gy+4)
+	}
+
+	slot := float64(plot.Dx()) / float64(len(reported))
+	center := func(i int) int {
+		return plot.Min.X + int((float64(i)+0.5)*slot)
+	}
+
+	for i, v := range reported {
+		x0 := plot.Min.X + int(float64(i)*slot) + 1
+		x1 := plot.Min.X + int(float64(i+1)*slot) - 1
+		if x1 <= x0 {
+			x1 = x0 + 1
+		}
+		draw.Draw(img, image.Rect(x0, y(float64(v)), x1, plot.Max.Y), &image.Uniform{chartBar}, image.Point{}, draw.Src)
+	}
+
+	for i := 1; i < len(averages); i++ {
+		drawLine(img, center(i-1), y(averages[i-1]), center(i), y(averages[i]), chartLine)
+	}
+
+	// dates of the first, middle and last day
+	for _, i := range []int{0, len(days) / 2, len(days) - 1} {
+		label := shortDate(days[i].Date)
+		x := center(i) - textWidth(label)/2
+		if x < plot.Min.X {
+			x = plot.Min.X
+		}
+		if x+textWidth(label) > chartWidth {
+			x = chartWidth - textWidth(label)
+		}
+		drawText(img, label, x, plot.Max.Y+18)
+	}
+
+	drawText(img, "Reported cases, last "+strconv.Itoa(len(reported))+" days", plot.Min.X, 20)
+	legend := "7 day average"
+	lx := plot.Max.X - textWidth(legend)
+	drawText(img, legend, lx, 20)
+	drawLine(img, lx-24, 16, lx-6, 16, chartLine)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func textWidth(s string) int {
+	return font.MeasureString(basicfont.Face7x13, s).Round()
+}
+
+// drawText writes s with its baseline at y.
+func drawText(img draw.Image, s string, x, y int) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  &image.Uniform{chartText},
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+// drawLine draws a line 3 pixels wide from (x0, y0) to (x1, y1).
+func drawLine(img draw.Image, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := x1-x0, y1-y0
+	steps := abs(dx)
+	if abs(dy) > steps {
+		steps = abs(dy)
+	}
+	if steps == 0 {
+		steps = 1
+	}
+	for i := 0; i <= steps; i++ {
+		x := x0 + dx*i/steps
+		y := y0 + dy*i/steps
+		draw.Draw(img, image.Rect(x-1, y-1, x+2, y+2), &image.Uniform{c}, image.Point{}, draw.Src)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
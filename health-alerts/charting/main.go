@@ -1,23 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"log"
-	"net/http"
 	"os"
-	"time"
-)
 
-type CaseResponse struct {
-	Payload    []Payload `json:"payload"`
-	StatusCode int       `json:"status_code"`
-}
-type Payload struct {
-	ID       int    `json:"id"`
-	Date     string `json:"date"`
-	Reported int    `json:"reported"`
-	Total    int    `json:"total"`
-}
+	"github.com/adityaxdiwakar/gt-cases/backend/client"
+)
 
 type PlotlyConfig struct {
 	Data []struct {
@@ -414,25 +405,25 @@ type PlotlyConfig struct {
 }
 
 func main() {
-	res, err := http.Get("https://api.aditya.diwakar.io/gt-jpj/cases")
+	baseURL := flag.String("base-url", client.DefaultBaseURL, "base URL of the GT JPJ Tracking API")
+	timeout := flag.Duration("timeout", client.DefaultTimeout, "timeout for API requests")
+	apiKey := flag.String("api-key", "", "optional API key for a higher rate limit")
+	flag.Parse()
+
+	api := client.New(client.WithBaseURL(*baseURL), client.WithTimeout(*timeout), client.WithAPIKey(*apiKey))
+
+	cases, err := api.Cases(context.Background())
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer res.Body.Close()
-
-	casesWrapped := CaseResponse{}
-	json.NewDecoder(res.Body).Decode(&casesWrapped)
-
-	json.NewEncoder(os.Stdout).Encode(casesWrapped.Payload)
+	json.NewEncoder(os.Stdout).Encode(cases)
 	dates := []string{}
 	reported := []int{}
 
-	for _, data := range casesWrapped.Payload {
+	for _, data := range cases {
 		reported = append(reported, data.Reported)
-
-		t, _ := time.Parse("January 2, 2006", data.Date)
-		dates = append(dates, t.Format("2006-01-02"))
+		dates = append(dates, data.Date)
 	}
 
 	config := PlotlyConfig{}
@@ -1,19 +1,21 @@
 package main
 
 import (
-	"bytes"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/PuerkitoBio/goquery"
-	"github.com/bwmarrin/discordgo"
+	"github.com/adityaxdiwakar/gt-cases/backend/client"
+	"github.com/adityaxdiwakar/gt-cases/backend/events"
+	"github.com/adityaxdiwakar/gt-cases/backend/gtdate"
+	"github.com/adityaxdiwakar/gt-cases/backend/notify"
 	"github.com/go-redis/redis/v8"
 	_ "github.com/lib/pq"
 	"golang.org/x/net/context"
@@ -21,13 +23,39 @@ import (
 
 var rdb *redis.Client
 var db *sql.DB
+var api *client.Client
 var conf tomlConfig
+var templates *notify.Templates
 var ctx = context.Background()
 
+// opsSource names the scraper in ops alerts.
+const opsSource = "health-alerts"
+
 type tomlConfig struct {
-	Redis    redisCredentials
-	Database postgresCredentials
-	Webhook  []string
+	Redis     redisCredentials
+	Database  postgresCredentials
+	API       apiConfig
+	Webhook   []string // no longer used, see `backend subscription add`
+	Templates string   // directory of notification templates, see notify.LoadTemplates
+	Rules     []notify.Rule
+	Combined  notify.Combined // one daily post for both datasets, shared with the other scraper
+	Ops       notify.Ops      // where failures of the scraper are reported
+}
+
+type caseFigures struct {
+	Reported int `json:"reported"`
+	Total    int `json:"total"`
+}
+
+// values keys the figures as notification templates expect them.
+func (f caseFigures) values() map[string]int {
+	return map[string]int{"reported": f.Reported, "total": f.Total}
+}
+
+type apiConfig struct {
+	BaseURL string
+	Timeout int    // seconds
+	Key     string // optional API key for a higher rate limit
 }
 
 type redisCredentials struct {
@@ -44,21 +72,44 @@ type postgresCredentials struct {
 	DBName   string
 }
 
-func init() {
+// setup loads config.toml, the templates and alert rules, and connects to
+// Redis and Postgres.
+func setup() {
 	if _, err := toml.DecodeFile("config.toml", &conf); err != nil {
 		log.Fatalf("error: could not parse configuration %v\n", err)
 	}
 
+	if len(conf.Webhook) > 0 {
+		log.Printf("warning: Webhook in config.toml is ignored, add the URLs with `backend subscription add -datasets %s`\n", "cases")
+	}
+
+	var err error
+	if templates, err = notify.LoadTemplates(conf.Templates); err != nil {
+		log.Fatalf("error: could not load templates: %v\n", err)
+	}
+	if conf.Rules, err = notify.CheckRules(conf.Rules, "cases"); err != nil {
+		log.Fatalf("error: invalid alert rules: %v\n", err)
+	}
+	if conf.Combined.Enabled {
+		if err := conf.Combined.Check(); err != nil {
+			log.Fatalf("error: invalid Combined configuration: %v\n", err)
+		}
+	}
+	if err := conf.Ops.Check(); err != nil {
+		log.Fatalf("error: invalid Ops configuration: %v\n", err)
+	}
+
 	rdb = redis.NewClient(&redis.Options{
 		Addr:     conf.Redis.Address,
 		Password: conf.Redis.Password,
 		DB:       conf.Redis.DB,
 	})
 
-	_, err := rdb.Ping(ctx).Result()
+	_, err = rdb.Ping(ctx).Result()
 	if err != nil {
-		log.Fatalf("error: could not make connection with redis: %v\n", err)
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckRedis, fmt.Errorf("could not make connection with redis: %v", err))
 	}
+	conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckRedis)
 
 	pSqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s "+
 		"sslmode=disable", conf.Database.Host, conf.Database.Port,
@@ -66,28 +117,41 @@ func init() {
 
 	db, err = sql.Open("postgres", pSqlInfo)
 	if err != nil {
-		log.Fatal(err)
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckDatabase, err)
 	}
 
 	err = db.Ping()
 	if err != nil {
-		log.Fatal(err)
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckDatabase, err)
 	}
+
+	api = newAPIClient(conf.API)
 }
 
-type JPJApiReport struct {
-	Payload    []JPJPayload `json:"payload"`
-	StatusCode int          `json:"status_code"`
+func newAPIClient(c apiConfig) *client.Client {
+	opts := []client.Option{}
+	if c.BaseURL != "" {
+		opts = append(opts, client.WithBaseURL(c.BaseURL))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, client.WithTimeout(time.Duration(c.Timeout)*time.Second))
+	}
+	if c.Key != "" {
+		opts = append(opts, client.WithAPIKey(c.Key))
+	}
+	return client.New(opts...)
 }
 
-type JPJPayload struct {
-	ID       int    `json:"id"`
-	Date     string `json:"date"`
-	Reported int    `json:"reported"`
-	Total    int    `json:"total"`
+// averageLast is the mean reported over the last n days of cases, or over
+// all of them while fewer have been reported.
+func averageLast(cases []client.CaseDay, n int) float64 {
+	if len(cases) > n {
+		cases = cases[len(cases)-n:]
+	}
+	return averagePayload(cases)
 }
 
-func averagePayload(slice []JPJPayload) float64 {
+func averagePayload(slice []client.CaseDay) float64 {
 	sum := 0
 	for _, k := range slice {
 		sum += k.Reported
@@ -95,125 +159,164 @@ func averagePayload(slice []JPJPayload) float64 {
 	return float64(sum) / float64(len(slice))
 }
 
+// caseDays converts cases for the alert rules, the last day carrying the
+// figures just scraped.
+func caseDays(cases []client.CaseDay, today caseFigures) []notify.Day {
+	days := make([]notify.Day, len(cases))
+	for i, c := range cases {
+		days[i] = notify.Day{Date: c.Date, Values: caseFigures{Reported: c.Reported, Total: c.Total}.values()}
+	}
+	if len(days) > 0 {
+		days[len(days)-1].Values = today.values()
+	}
+	return days
+}
+
+// parsePage reads the latest day off GT's page, failing rather than
+// panicking if its layout changed.
+func parsePage(doc *goquery.Document) (date string, figures caseFigures, footnoted bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("unexpected page layout: %v", r)
+		}
+	}()
+
+	teaser := doc.Find(".super-block__teaser").Nodes[0]
+	table := goquery.NewDocumentFromNode(teaser).Children().Nodes[0]
+	row := goquery.NewDocumentFromNode(table).Children().Nodes[1]
+	tr := goquery.NewDocumentFromNode(row).Children().Nodes[0]
+	data := goquery.NewDocumentFromNode(tr).Children().Nodes
+
+	date = data[0].FirstChild.Data
+	reported := data[1].FirstChild.Data
+	aggregation := data[2].FirstChild.Data
+
+	// GT marks some counts with an asterisk and explains it on the page
+	footnoted = strings.Contains(reported, "*")
+	reported = strings.Replace(reported, "*", "", 1)
+
+	if figures.Reported, err = strconv.Atoi(strings.Replace(reported, ",", "", -1)); err != nil {
+		return
+	}
+	figures.Total, err = strconv.Atoi(strings.Replace(aggregation, ",", "", -1))
+	return
+}
+
 func main() {
+	setup()
+
+	// held back daily posts go out on the first run past the deadline,
+	// whether or not there is anything new
+	if conf.Combined.Enabled {
+		conf.Combined.Flush(ctx, db, templates)
+	}
+
 	transport := &http.Transport{
 		DialContext: (&net.Dialer{
 			Timeout: 5 * time.Second,
 		}).DialContext,
 	}
 
-	client := http.Client{Transport: transport}
+	httpClient := http.Client{Transport: transport}
 
 	req, err := http.NewRequest("GET", "https://health.gatech.edu/coronavirus/health-alerts", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	res, err := client.Do(req)
+	res, err := httpClient.Do(req)
+	if err == nil && res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		err = fmt.Errorf("GT answered %s", res.Status)
+	}
 	if err != nil {
-		log.Fatal(err)
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckFetch, err)
 	}
 
 	defer res.Body.Close()
 
 	doc, err := goquery.NewDocumentFromReader(res.Body)
 	if err != nil {
-		log.Fatal(err)
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckFetch, err)
 	}
+	conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckFetch)
 
-	teaser := doc.Find(".super-block__teaser").Nodes[0]
-	table := goquery.NewDocumentFromNode(teaser).Children().Nodes[0]
-	row := goquery.NewDocumentFromNode(table).Children().Nodes[1]
-	tr := goquery.NewDocumentFromNode(row).Children().Nodes[0]
-	data := goquery.NewDocumentFromNode(tr).Children().Nodes
-
-	date := data[0].FirstChild.Data
-	reported := data[1].FirstChild.Data
-	aggregation := data[2].FirstChild.Data
-
-	reported = strings.Replace(reported, "*", "", 1)
+	date, figures, footnoted, err := parsePage(doc)
+	if err != nil {
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckParse, err)
+	}
+	conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckParse)
+	reportedInt, totalInt := figures.Reported, figures.Total
 
 	previousDate, err := rdb.Get(ctx, "gt.cases.lastdate").Result()
-	if previousDate != date {
+	if previousDate == date {
+		// GT occasionally corrects a day after publishing it
+		if err := notify.ReviseDay(ctx, db, rdb, templates, "cases", date, figures.values(), func() *notify.File {
+			return revisedChart(figures)
+		}); err != nil {
+			conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckDatabase, err)
+		}
+		conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckDatabase)
+		conf.Ops.CheckFreshness(ctx, rdb, templates, opsSource)
 
-		sqlStatement := `
-        INSERT INTO cases (date, reported, total) 
-        VALUES ($1, $2, $3)`
+		if len(conf.Rules) > 0 {
+			cases, err := api.Cases(ctx)
+			if err != nil {
+				conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckAPI, err)
+			}
+			conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckAPI)
+			notify.FireRules(ctx, db, rdb, templates, conf.Rules, "cases", date, caseDays(cases, figures))
+		}
+	} else {
 
-		_, err := db.Exec(sqlStatement, date, reported, aggregation)
+		id, err := events.AddDay(db, "cases", date, figures.values())
 		if err != nil {
-			log.Fatal(err)
+			conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckDatabase, err)
 		} else {
 			// only set redis value if DB insertion was successful
 			rdb.Set(ctx, "gt.cases.lastdate", date, 0)
 		}
+		conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckDatabase)
+		conf.Ops.MarkFresh(ctx, rdb, templates, opsSource)
 
-		resp, err := client.Get("https://api.aditya.diwakar.io/gt-jpj/cases")
-		if err != nil {
-			log.Fatal(err)
+		if _, err := events.Publish(ctx, db, rdb, events.KindCase, date, figures); err != nil {
+			log.Println(err)
 		}
 
-		defer resp.Body.Close()
-
-		var jpjResponse JPJApiReport
-		err = json.NewDecoder(resp.Body).Decode(&jpjResponse)
+		cases, err := api.Cases(ctx)
 		if err != nil {
-			log.Fatal(err)
+			conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckAPI, err)
 		}
+		conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckAPI)
 
-		payloadLength := len(jpjResponse.Payload)
-
-		sevenDayMA := averagePayload(jpjResponse.Payload[payloadLength-7 : payloadLength])
-		thirtyDayMA := averagePayload(jpjResponse.Payload[payloadLength-30 : payloadLength])
-
-		webhookMessage := discordgo.WebhookParams{
-			Username:  "GT Stamps Health Services",
-			AvatarURL: "https://img.aditya.diwakar.io/stamps.png",
-			Embeds: []*discordgo.MessageEmbed{
-				{
-					Title: fmt.Sprintf("[%s] GT COVID-19 Update", date),
-					URL:   "https://health.gatech.edu/coronavirus/health-alerts",
-					Color: 11772777,
-					Footer: &discordgo.MessageEmbedFooter{
-						Text: "Made with ❤️ by Aditya Diwakar",
-					},
-					Fields: []*discordgo.MessageEmbedField{
-						{
-							Name:   "Reported Today",
-							Value:  reported,
-							Inline: true,
-						},
-						{
-							Name:   "Total",
-							Value:  aggregation,
-							Inline: true,
-						},
-						{
-							Name:   "7/30 Day MA",
-							Value:  fmt.Sprintf("%.1f/%.1f", sevenDayMA, thirtyDayMA),
-							Inline: true,
-						},
-					},
-				},
-			},
+		// the backend refreshes asynchronously and may not have today yet;
+		// its days are dated in ISO 8601, so today is too
+		if len(cases) == 0 || cases[len(cases)-1].ID != id {
+			cases = append(cases, client.CaseDay{ID: id, Date: gtdate.ISO(date), Reported: reportedInt, Total: totalInt})
 		}
 
-		jsonStr, _ := json.Marshal(webhookMessage)
+		payloadLength := len(cases)
 
-		for _, wh := range conf.Webhook {
-			req, err := http.NewRequest("POST", wh, bytes.NewBuffer(jsonStr))
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			req.Header.Set("Content-Type", "application/json")
+		sevenDayMA := averageLast(cases, 7)
+		thirtyDayMA := averageLast(cases, 30)
 
-			res, err = client.Do(req)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
+		var previous map[string]int
+		if payloadLength > 1 {
+			prev := cases[payloadLength-2]
+			previous = caseFigures{Reported: prev.Reported, Total: prev.Total}.values()
 		}
 
+		data := notify.NewData("cases", date, figures.values(), previous)
+		data.Averages["seven"], data.Averages["thirty"] = sevenDayMA, thirtyDayMA
+		data.Series = series(cases, chartDays)
+		data.Chart = chartFile(cases)
+		if footnoted {
+			data.Footnotes = append(data.Footnotes, "* see GT's page for a note on today's count")
+		}
+
+		notify.Broadcast(ctx, db, templates, conf.Combined, "cases", notify.AlertDaily, "cases.daily", data)
+
+		notify.FireRules(ctx, db, rdb, templates, conf.Rules, "cases", date, caseDays(cases, figures))
+
 	}
 }
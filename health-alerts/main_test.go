@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/client"
+)
+
+func TestAverageLast(t *testing.T) {
+	days := func(reported ...int) []client.CaseDay {
+		cases := make([]client.CaseDay, len(reported))
+		for i, n := range reported {
+			cases[i] = client.CaseDay{ID: i + 1, Reported: n}
+		}
+		return cases
+	}
+
+	tests := []struct {
+		name  string
+		cases []client.CaseDay
+		n     int
+		want  float64
+	}{
+		{"one day", days(4), 7, 4},
+		{"fewer than n", days(1, 2, 3), 30, 2},
+		{"exactly n", days(1, 2, 3), 3, 2},
+		{"more than n", days(100, 1, 2, 3), 3, 2},
+	}
+
+	for _, tt := range tests {
+		if got := averageLast(tt.cases, tt.n); got != tt.want {
+			t.Errorf("%s: averageLast = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,16 +1,5 @@
 package main
 
-type CaseResponse struct {
-	Payload    []Payload `json:"payload"`
-	StatusCode int       `json:"status_code"`
-}
-type Payload struct {
-	ID       int    `json:"id"`
-	Date     string `json:"date"`
-	Reported int    `json:"reported"`
-	Total    int    `json:"total"`
-}
-
 type PlotlyConfig struct {
 	Data []struct {
 		Type string   `json:"type"`
@@ -5,8 +5,10 @@ go 1.14
 require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/PuerkitoBio/goquery v1.5.1
-	github.com/bwmarrin/discordgo v0.22.0
+	github.com/adityaxdiwakar/gt-cases/backend v0.0.0-00010101000000-000000000000
 	github.com/go-redis/redis/v8 v8.0.0-beta.7
 	github.com/lib/pq v1.8.0
 	golang.org/x/net v0.0.0-20200813134508-3edf25e44fcc
 )
+
+replace github.com/adityaxdiwakar/gt-cases/backend => ../backend
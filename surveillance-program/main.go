@@ -1,38 +1,60 @@
 package main
 
 import (
-	"bytes"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/PuerkitoBio/goquery"
-	"github.com/bwmarrin/discordgo"
+	"github.com/adityaxdiwakar/gt-cases/backend/client"
+	"github.com/adityaxdiwakar/gt-cases/backend/events"
+	"github.com/adityaxdiwakar/gt-cases/backend/notify"
 	"github.com/go-redis/redis/v8"
 	_ "github.com/lib/pq"
 	"golang.org/x/net/context"
-	"golang.org/x/text/language"
-	"golang.org/x/text/message"
 )
 
 var rdb *redis.Client
 var db *sql.DB
+var api *client.Client
 var conf tomlConfig
+var templates *notify.Templates
 var ctx = context.Background()
-var p *message.Printer
+
+// opsSource names the scraper in ops alerts.
+const opsSource = "surveillance-program"
 
 type tomlConfig struct {
-	Redis    redisCredentials
-	Database postgresCredentials
-	Webhook  []string
+	Redis     redisCredentials
+	Database  postgresCredentials
+	API       apiConfig
+	Webhook   []string // no longer used, see `backend subscription add`
+	Templates string   // directory of notification templates, see notify.LoadTemplates
+	Rules     []notify.Rule
+	Combined  notify.Combined // one daily post for both datasets, shared with the other scraper
+	Ops       notify.Ops      // where failures of the scraper are reported
+}
+
+type surveyFigures struct {
+	Positive     int `json:"positive"`
+	Administered int `json:"administered"`
+}
+
+// values keys the figures as notification templates expect them.
+func (f surveyFigures) values() map[string]int {
+	return map[string]int{"positive": f.Positive, "administered": f.Administered}
+}
+
+type apiConfig struct {
+	BaseURL string
+	Timeout int    // seconds
+	Key     string // optional API key for a higher rate limit
 }
 
 type redisCredentials struct {
@@ -49,21 +71,44 @@ type postgresCredentials struct {
 	DBName   string
 }
 
-func init() {
+// setup loads config.toml, the templates and alert rules, and connects to
+// Redis and Postgres.
+func setup() {
 	if _, err := toml.DecodeFile("config.toml", &conf); err != nil {
 		log.Fatalf("error: could not parse configuration %v\n", err)
 	}
 
+	if len(conf.Webhook) > 0 {
+		log.Printf("warning: Webhook in config.toml is ignored, add the URLs with `backend subscription add -datasets %s`\n", "surveys")
+	}
+
+	var err error
+	if templates, err = notify.LoadTemplates(conf.Templates); err != nil {
+		log.Fatalf("error: could not load templates: %v\n", err)
+	}
+	if conf.Rules, err = notify.CheckRules(conf.Rules, "surveys"); err != nil {
+		log.Fatalf("error: invalid alert rules: %v\n", err)
+	}
+	if conf.Combined.Enabled {
+		if err := conf.Combined.Check(); err != nil {
+			log.Fatalf("error: invalid Combined configuration: %v\n", err)
+		}
+	}
+	if err := conf.Ops.Check(); err != nil {
+		log.Fatalf("error: invalid Ops configuration: %v\n", err)
+	}
+
 	rdb = redis.NewClient(&redis.Options{
 		Addr:     conf.Redis.Address,
 		Password: conf.Redis.Password,
 		DB:       conf.Redis.DB,
 	})
 
-	_, err := rdb.Ping(ctx).Result()
+	_, err = rdb.Ping(ctx).Result()
 	if err != nil {
-		log.Fatalf("error: could not make connection with redis: %v\n", err)
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckRedis, fmt.Errorf("could not make connection with redis: %v", err))
 	}
+	conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckRedis)
 
 	pSqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s "+
 		"sslmode=disable", conf.Database.Host, conf.Database.Port,
@@ -71,53 +116,48 @@ func init() {
 
 	db, err = sql.Open("postgres", pSqlInfo)
 	if err != nil {
-		log.Fatal(err)
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckDatabase, err)
 	}
 
 	err = db.Ping()
 	if err != nil {
-		log.Fatal(err)
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckDatabase, err)
 	}
 
-	p = message.NewPrinter(language.English)
-}
-
-type SurveyResponse struct {
-	Payload    []Payload `json:"payload"`
-	StatusCode int       `json:"status_code"`
-}
-type Payload struct {
-	ID           int    `json:"id"`
-	Date         string `json:"date"`
-	Positive     int    `json:"positive"`
-	Administered int    `json:"administered"`
+	api = newAPIClient(conf.API)
 }
 
-func main() {
-	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout: 5 * time.Second,
-		}).DialContext,
+func newAPIClient(c apiConfig) *client.Client {
+	opts := []client.Option{}
+	if c.BaseURL != "" {
+		opts = append(opts, client.WithBaseURL(c.BaseURL))
 	}
-
-	client := http.Client{Transport: transport}
-
-	req, err := http.NewRequest("GET", "https://health.gatech.edu/surveillance-testing-program-results", nil)
-	if err != nil {
-		log.Fatal(err)
+	if c.Timeout > 0 {
+		opts = append(opts, client.WithTimeout(time.Duration(c.Timeout)*time.Second))
 	}
-
-	res, err := client.Do(req)
-	if err != nil {
-		log.Fatal(err)
+	if c.Key != "" {
+		opts = append(opts, client.WithAPIKey(c.Key))
 	}
+	return client.New(opts...)
+}
 
-	defer res.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		log.Fatal(err)
+// surveyDays converts surveys for the alert rules.
+func surveyDays(surveys []client.SurveyDay) []notify.Day {
+	days := make([]notify.Day, len(surveys))
+	for i, s := range surveys {
+		days[i] = notify.Day{Date: s.Date, Values: surveyFigures{Positive: s.Positive, Administered: s.Administered}.values()}
 	}
+	return days
+}
+
+// parsePage reads the latest results off GT's page, failing rather than
+// panicking if its layout changed.
+func parsePage(doc *goquery.Document) (date string, figures surveyFigures, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("unexpected page layout: %v", r)
+		}
+	}()
 
 	teaser := doc.Find(".super-block__teaser").Nodes[0]
 	table := goquery.NewDocumentFromNode(teaser).Children().Nodes[0]
@@ -127,7 +167,7 @@ func main() {
 	headerTr := goquery.NewDocumentFromNode(header).Children().Nodes[0]
 	headerTh := goquery.NewDocumentFromNode(headerTr).Children().Nodes[0]
 	headerP := goquery.NewDocumentFromNode(headerTh).Children().Nodes[0]
-	date := headerP.FirstChild.LastChild.FirstChild.Data
+	date = headerP.FirstChild.LastChild.FirstChild.Data
 
 	row := goquery.NewDocumentFromNode(table).Children().Nodes[1]
 	tr := goquery.NewDocumentFromNode(row).Children().Nodes
@@ -140,97 +180,108 @@ func main() {
 	positive = strings.Replace(positive, ",", "", -1)
 	total = strings.Replace(total, ",", "", -1)
 
-	positiveInt, err := strconv.Atoi(positive)
-	if err != nil {
-		log.Fatal(err)
+	if figures.Positive, err = strconv.Atoi(positive); err != nil {
+		return
 	}
-	totalInt, err := strconv.Atoi(total)
-	if err != nil {
-		log.Fatal(err)
+	figures.Administered, err = strconv.Atoi(total)
+	return
+}
+
+func main() {
+	setup()
+
+	// held back daily posts go out on the first run past the deadline,
+	// whether or not there is anything new
+	if conf.Combined.Enabled {
+		conf.Combined.Flush(ctx, db, templates)
+	}
+
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+		}).DialContext,
 	}
 
-	log.Println(positiveInt, totalInt)
+	client := http.Client{Transport: transport}
 
-	// grab the latest record from the API
-	res, err = http.Get("https://api.aditya.diwakar.io/gt-jpj/testing")
+	req, err := http.NewRequest("GET", "https://health.gatech.edu/surveillance-testing-program-results", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	res, err := client.Do(req)
+	if err == nil && res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		err = fmt.Errorf("GT answered %s", res.Status)
+	}
+	if err != nil {
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckFetch, err)
+	}
+
 	defer res.Body.Close()
 
-	target := SurveyResponse{}
-	err = json.NewDecoder(res.Body).Decode(&target)
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckFetch, err)
+	}
+	conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckFetch)
 
-	previousSurveyDate := target.Payload[len(target.Payload)-1]
-	json.NewEncoder(os.Stdout).Encode(previousSurveyDate)
+	date, figures, err := parsePage(doc)
+	if err != nil {
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckParse, err)
+	}
+	conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckParse)
+	// grab the latest record from the API
+	surveys, err := api.Surveys(ctx)
+	if err != nil {
+		conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckAPI, err)
+	}
+	conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckAPI)
 
 	previousDate, _ := rdb.Get(ctx, "gt.survey.lastdate").Result()
-	if previousDate != date {
+	if previousDate == date {
+		// GT occasionally corrects results after publishing them
+		if err := notify.ReviseDay(ctx, db, rdb, templates, "surveys", date, figures.values(), nil); err != nil {
+			conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckDatabase, err)
+		}
+		conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckDatabase)
+		conf.Ops.CheckFreshness(ctx, rdb, templates, opsSource)
+
+		// the latest result is the one just revised
+		days := surveyDays(surveys)
+		if len(days) > 0 {
+			days[len(days)-1].Values = figures.values()
+			notify.FireRules(ctx, db, rdb, templates, conf.Rules, "surveys", date, days)
+		}
+	} else {
 		rdb.Set(ctx, "gt.survey.lastdate", date, 0)
 
-		sqlStatement := `
-        INSERT INTO surveys (date, positive, administered) 
-        VALUES ($1, $2, $3)`
-
-		_, err := db.Exec(sqlStatement, date, positiveInt, totalInt)
+		id, err := events.AddDay(db, "surveys", date, figures.values())
 		if err != nil {
-			log.Fatal(err)
+			conf.Ops.Fatal(ctx, rdb, templates, opsSource, notify.CheckDatabase, err)
 		}
+		conf.Ops.Recovered(ctx, rdb, templates, opsSource, notify.CheckDatabase)
+		conf.Ops.MarkFresh(ctx, rdb, templates, opsSource)
 
-		stringPositive := p.Sprintf("%d", positiveInt-previousSurveyDate.Positive)
-		if positiveInt-previousSurveyDate.Positive > 0 {
-			stringPositive = "+" + stringPositive
+		if _, err := events.Publish(ctx, db, rdb, events.KindSurvey, date, figures); err != nil {
+			log.Println(err)
 		}
 
-		stringAdmin := p.Sprintf("%d", totalInt-previousSurveyDate.Administered)
-		if totalInt-previousSurveyDate.Administered > 0 {
-			stringAdmin = "+" + stringAdmin
+		// the API only has today already if it was added through the admin API
+		if len(surveys) > 0 && surveys[len(surveys)-1].ID == id {
+			surveys = surveys[:len(surveys)-1]
 		}
 
-		webhookMessage := discordgo.WebhookParams{
-			Username:  "GT Stamps Health Services",
-			AvatarURL: "https://img.aditya.diwakar.io/stamps.png",
-			Embeds: []*discordgo.MessageEmbed{
-				{
-					Title: fmt.Sprintf("[%s] Surveillance Testing Program Results ", date),
-					URL:   "https://health.gatech.edu/surveillance-testing-program-results",
-					Color: 11772777,
-					Footer: &discordgo.MessageEmbedFooter{
-						Text: "Made with ❤️ by Aditya Diwakar",
-					},
-					Fields: []*discordgo.MessageEmbedField{
-						{
-							Name:   "Tested Positive (All Time)",
-							Value:  p.Sprintf("%d (%s)", positiveInt, stringPositive),
-							Inline: true,
-						},
-						{
-							Name:   "Tests Administered",
-							Value:  p.Sprintf("%d (%s)", totalInt, stringAdmin),
-							Inline: true,
-						},
-					},
-				},
-			},
+		var previous map[string]int
+		if len(surveys) > 0 {
+			latest := surveys[len(surveys)-1]
+			previous = surveyFigures{Positive: latest.Positive, Administered: latest.Administered}.values()
 		}
+		data := notify.NewData("surveys", date, figures.values(), previous)
 
-		jsonStr, _ := json.Marshal(webhookMessage)
-
-		for _, wh := range conf.Webhook {
-			req, err := http.NewRequest("POST", wh, bytes.NewBuffer(jsonStr))
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			req.Header.Set("Content-Type", "application/json")
-
-			_, err = client.Do(req)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-		}
+		notify.Broadcast(ctx, db, templates, conf.Combined, "surveys", notify.AlertDaily, "surveys.daily", data)
+
+		notify.FireRules(ctx, db, rdb, templates, conf.Rules, "surveys", date, append(surveyDays(surveys), notify.Day{Date: date, Values: figures.values()}))
 
 	}
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/client"
+	"github.com/adityaxdiwakar/gt-cases/backend/notify"
+)
+
+const isoLayout = "2006-01-02"
+
+// lastWeek returns the Monday starting the last full week before now.
+func lastWeek(now time.Time) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	daysSinceMonday := (int(today.Weekday()) + 6) % 7
+	return today.AddDate(0, 0, -daysSinceMonday-7)
+}
+
+// inWeek reports whether the ISO date falls in the week starting on start.
+func inWeek(date string, start time.Time) bool {
+	t, err := time.Parse(isoLayout, date)
+	if err != nil {
+		return false
+	}
+	return !t.Before(start) && t.Before(start.AddDate(0, 0, 7))
+}
+
+// averageAt is the 7-day moving average of reported cases at the last day
+// reported before end, the way the daily alerts compute it.
+func averageAt(cases []client.CaseDay, end time.Time) float64 {
+	last := -1
+	for i, c := range cases {
+		if t, err := time.Parse(isoLayout, c.Date); err == nil && t.Before(end) {
+			last = i
+		}
+	}
+	if last < 0 {
+		return 0
+	}
+
+	first := last - 6
+	if first < 0 {
+		first = 0
+	}
+	sum := 0
+	for _, c := range cases[first : last+1] {
+		sum += c.Reported
+	}
+	return float64(sum) / float64(last+1-first)
+}
+
+// surveyTotalsAt returns the cumulative surveillance figures of the last
+// result before end.
+func surveyTotalsAt(surveys []client.SurveyDay, end time.Time) (positive, administered int) {
+	for _, s := range surveys {
+		if t, err := time.Parse(isoLayout, s.Date); err == nil && t.Before(end) {
+			positive, administered = s.Positive, s.Administered
+		}
+	}
+	return positive, administered
+}
+
+// summarize builds the digest of the week starting on start.
+func summarize(start time.Time, cases []client.CaseDay, surveys []client.SurveyDay) notify.Digest {
+	end := start.AddDate(0, 0, 7)
+	previous := start.AddDate(0, 0, -7)
+
+	d := notify.Digest{
+		Start: start.Format(isoLayout),
+		End:   end.AddDate(0, 0, -1).Format(isoLayout),
+		Chart: conf.ChartURL,
+	}
+
+	for _, c := range cases {
+		switch {
+		case inWeek(c.Date, start):
+			d.Cases += c.Reported
+			if c.Reported > d.Peak || d.PeakDate == "" {
+				d.PeakDate, d.Peak = c.Date, c.Reported
+			}
+		case inWeek(c.Date, previous):
+			d.PreviousCases += c.Reported
+		}
+	}
+	d.Change = d.Cases - d.PreviousCases
+
+	d.Average, d.PreviousAverage = averageAt(cases, end), averageAt(cases, start)
+	switch {
+	case d.Average > d.PreviousAverage:
+		d.Trend = "↑"
+	case d.Average < d.PreviousAverage:
+		d.Trend = "↓"
+	default:
+		d.Trend = "→"
+	}
+
+	positive, administered := surveyTotalsAt(surveys, end)
+	positiveBefore, administeredBefore := surveyTotalsAt(surveys, start)
+	d.Positives, d.Tests = positive-positiveBefore, administered-administeredBefore
+
+	return d
+}
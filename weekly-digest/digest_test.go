@@ -0,0 +1,161 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adityaxdiwakar/gt-cases/backend/client"
+	"github.com/adityaxdiwakar/gt-cases/backend/notify"
+	"github.com/adityaxdiwakar/gt-cases/backend/testenv"
+)
+
+func TestLastWeek(t *testing.T) {
+	tests := []struct {
+		now  string
+		want string
+	}{
+		{"2020-09-21", "2020-09-14"}, // Monday
+		{"2020-09-23", "2020-09-14"},
+		{"2020-09-27", "2020-09-14"}, // Sunday
+		{"2020-09-28", "2020-09-21"},
+		{"2021-01-04", "2020-12-28"},
+	}
+
+	for _, tt := range tests {
+		now, _ := time.Parse(isoLayout, tt.now)
+		if got := lastWeek(now.Add(15 * time.Hour)).Format(isoLayout); got != tt.want {
+			t.Errorf("lastWeek(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+// casesFrom returns a day of cases for each of reported, starting on date.
+func casesFrom(date string, reported ...int) []client.CaseDay {
+	start, _ := time.Parse(isoLayout, date)
+	cases := make([]client.CaseDay, len(reported))
+	for i, n := range reported {
+		cases[i] = client.CaseDay{ID: i + 1, Date: start.AddDate(0, 0, i).Format(isoLayout), Reported: n}
+	}
+	return cases
+}
+
+func TestSummarize(t *testing.T) {
+	start, _ := time.Parse(isoLayout, "2020-09-14")
+	surveys := []client.SurveyDay{
+		{Date: "2020-09-10", Positive: 10, Administered: 1000},
+		{Date: "2020-09-17", Positive: 14, Administered: 1800},
+		{Date: "2020-09-24", Positive: 20, Administered: 2500},
+	}
+
+	tests := []struct {
+		name    string
+		cases   []client.CaseDay
+		surveys []client.SurveyDay
+		want    notify.Digest
+	}{
+		{
+			name:    "rising",
+			cases:   casesFrom("2020-09-07", 1, 1, 1, 1, 1, 1, 1, 2, 5, 3, 5, 2, 2, 2),
+			surveys: surveys,
+			want: notify.Digest{
+				Start: "2020-09-14", End: "2020-09-20",
+				Cases: 21, PreviousCases: 7, Change: 14,
+				PeakDate: "2020-09-15", Peak: 5,
+				Average: 3, PreviousAverage: 1, Trend: "↑",
+				Tests: 800, Positives: 4,
+			},
+		},
+		{
+			name:  "falling",
+			cases: casesFrom("2020-09-07", 4, 4, 4, 4, 4, 4, 4, 1, 1, 1, 1, 1, 1, 1),
+			want: notify.Digest{
+				Start: "2020-09-14", End: "2020-09-20",
+				Cases: 7, PreviousCases: 28, Change: -21,
+				PeakDate: "2020-09-14", Peak: 1,
+				Average: 1, PreviousAverage: 4, Trend: "↓",
+			},
+		},
+		{
+			name:  "steady and days after the week",
+			cases: casesFrom("2020-09-07", 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 9),
+			want: notify.Digest{
+				Start: "2020-09-14", End: "2020-09-20",
+				Cases: 14, PreviousCases: 14,
+				PeakDate: "2020-09-14", Peak: 2,
+				Average: 2, PreviousAverage: 2, Trend: "→",
+			},
+		},
+		{
+			name:  "nothing reported",
+			cases: casesFrom("2020-09-07", 1, 2),
+			want: notify.Digest{
+				Start: "2020-09-14", End: "2020-09-20",
+				PreviousCases: 3, Change: -3,
+				Average: 1.5, PreviousAverage: 1.5, Trend: "→",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := summarize(start, tt.cases, tt.surveys); got != tt.want {
+			t.Errorf("%s: summarize() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClaimWeek(t *testing.T) {
+	db = testenv.Database(t)
+	_, err := db.Exec(`CREATE TABLE digests (
+		week       DATE PRIMARY KEY,
+		sent_at    TIMESTAMPTZ,
+		status     TEXT NOT NULL DEFAULT 'sent',
+		claimed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	week, _ := time.Parse(isoLayout, "2020-09-14")
+
+	age := func(d time.Duration) {
+		if _, err := db.Exec(`UPDATE digests SET claimed_at = now() - make_interval(secs => $1)`, d.Seconds()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := func() {
+		if err := markSent(week); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		before func()
+		want   bool
+	}{
+		{"first run", func() {}, true},
+		{"while delivering", func() {}, false},
+		{"recent claim", func() { age(claimExpiry / 2) }, false},
+		{"expired claim", func() { age(2 * claimExpiry) }, true},
+		{"sent", sent, false},
+		{"sent long ago", func() { age(2 * claimExpiry) }, false},
+	}
+
+	for _, tt := range tests {
+		tt.before()
+		got, err := claimWeek(week)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: claimWeek = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	var status string
+	if err := db.QueryRow(`SELECT status FROM digests WHERE week = $1`, week).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status != "sent" {
+		t.Errorf("status = %s, want sent", status)
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/adityaxdiwakar/gt-cases/weekly-digest
+
+go 1.14
+
+require (
+	github.com/BurntSushi/toml v0.3.1
+	github.com/adityaxdiwakar/gt-cases/backend v0.0.0-00010101000000-000000000000
+	github.com/lib/pq v1.8.0
+)
+
+replace github.com/adityaxdiwakar/gt-cases/backend => ../backend
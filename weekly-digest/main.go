@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/adityaxdiwakar/gt-cases/backend/client"
+	"github.com/adityaxdiwakar/gt-cases/backend/notify"
+	_ "github.com/lib/pq"
+)
+
+var db *sql.DB
+var api *client.Client
+var conf tomlConfig
+var templates *notify.Templates
+var ctx = context.Background()
+
+type tomlConfig struct {
+	Database  postgresCredentials
+	API       apiConfig
+	Templates string // directory of notification templates, see notify.LoadTemplates
+	ChartURL  string // a chart of reported cases linked from the digest, none if empty
+}
+
+type apiConfig struct {
+	BaseURL string
+	Timeout int    // seconds
+	Key     string // optional API key for a higher rate limit
+}
+
+type postgresCredentials struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	DBName   string
+}
+
+// setup loads config.toml and the templates and connects to Postgres.
+func setup() {
+	if _, err := toml.DecodeFile("config.toml", &conf); err != nil {
+		log.Fatalf("error: could not parse configuration %v\n", err)
+	}
+
+	var err error
+	if templates, err = notify.LoadTemplates(conf.Templates); err != nil {
+		log.Fatalf("error: could not load templates: %v\n", err)
+	}
+
+	pSqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s "+
+		"sslmode=disable", conf.Database.Host, conf.Database.Port,
+		conf.Database.User, conf.Database.Password, conf.Database.DBName)
+
+	db, err = sql.Open("postgres", pSqlInfo)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = db.Ping()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	api = newAPIClient(conf.API)
+}
+
+func newAPIClient(c apiConfig) *client.Client {
+	opts := []client.Option{}
+	if c.BaseURL != "" {
+		opts = append(opts, client.WithBaseURL(c.BaseURL))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, client.WithTimeout(time.Duration(c.Timeout)*time.Second))
+	}
+	if c.Key != "" {
+		opts = append(opts, client.WithAPIKey(c.Key))
+	}
+	return client.New(opts...)
+}
+
+// claimExpiry is how long a claim on a week holds before a later run may
+// take it over, in case the run that made it died before delivering.
+const claimExpiry = time.Hour
+
+// claimWeek records that the digest of week is being sent, and reports
+// false if it already was or another run is sending it, so that reruns of
+// the job send nothing.
+func claimWeek(week time.Time) (bool, error) {
+	res, err := db.Exec(`INSERT INTO digests (week, status, claimed_at) VALUES ($1, 'claimed', now())
+		ON CONFLICT (week) DO UPDATE SET claimed_at = now()
+		WHERE digests.status = 'claimed' AND digests.claimed_at < now() - make_interval(secs => $2)`,
+		week.Format(isoLayout), claimExpiry.Seconds())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// markSent records that the digest of week has been delivered.
+func markSent(week time.Time) error {
+	_, err := db.Exec(`UPDATE digests SET status = 'sent', sent_at = now() WHERE week = $1`, week.Format(isoLayout))
+	return err
+}
+
+// subscribers returns the destinations of the weekly digest of either
+// dataset, each once.
+func subscribers() ([]notify.Destination, error) {
+	seen := make(map[int]bool)
+	list := []notify.Destination{}
+
+	for _, dataset := range []string{"cases", "surveys"} {
+		destinations, err := notify.Subscribers(db, dataset, notify.AlertWeekly)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range destinations {
+			if !seen[d.ID] {
+				seen[d.ID] = true
+				list = append(list, d)
+			}
+		}
+	}
+
+	return list, nil
+}
+
+// main sends the digest of the last full week, Monday to Sunday. Run it
+// from cron on Mondays; a week is only ever sent once.
+func main() {
+	week := flag.String("week", "", "send the digest of the week starting on this Monday (YYYY-MM-DD) instead of last week")
+	flag.Parse()
+	setup()
+
+	start := lastWeek(time.Now())
+	if *week != "" {
+		t, err := time.Parse(isoLayout, *week)
+		if err != nil || t.Weekday() != time.Monday {
+			log.Fatalf("error: -week must be a Monday formatted as YYYY-MM-DD\n")
+		}
+		start = t
+	}
+
+	cases, err := api.Cases(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	surveys, err := api.Surveys(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data := notify.Data{Dataset: "digest", Link: notify.Links["cases"]}
+	data.Digest = summarize(start, cases, surveys)
+	data.Date = data.Digest.Start
+
+	// the claim is committed before delivering, which may take minutes of
+	// retries, and the week only marked sent after
+	claimed, err := claimWeek(start)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !claimed {
+		log.Printf("digest of the week of %s is being or was already sent\n", start.Format(isoLayout))
+		return
+	}
+
+	list, err := subscribers()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	notify.Deliver(ctx, db, templates, list, "digest", data)
+
+	if err := markSent(start); err != nil {
+		log.Fatal(err)
+	}
+}